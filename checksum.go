@@ -0,0 +1,268 @@
+package ose
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+var hashAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// NewHash creates a hash of algorithm, one of md5, sha1, sha256 and sha512.
+func NewHash(algorithm string) (hash.Hash, error) {
+	f, ok := hashAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash algorithm: %s", algorithm)
+	}
+	return f(), nil
+}
+
+func newHashes(algorithms []string) (map[string]hash.Hash, io.Writer, error) {
+	hs := make(map[string]hash.Hash)
+	ws := make([]io.Writer, 0, len(algorithms))
+	for _, algorithm := range algorithms {
+		h, err := NewHash(algorithm)
+		if err != nil {
+			return nil, nil, err
+		}
+		hs[algorithm] = h
+		ws = append(ws, h)
+	}
+	return hs, io.MultiWriter(ws...), nil
+}
+
+// ChecksumMismatchError is returned when the digest of data differs from the expected one.
+type ChecksumMismatchError struct {
+	Name      string
+	Algorithm string
+	Expected  string
+	Actual    string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s: %s mismatch: expected %s, actual %s", e.Name, e.Algorithm, e.Expected, e.Actual)
+}
+
+// HashReadCloser implements io.ReadCloser.
+// It computes the digests of the data read from the underlying ReadCloser.
+type HashReadCloser struct {
+	ReadCloser io.ReadCloser
+	Hashes     map[string]hash.Hash
+	w          io.Writer
+}
+
+// NewHashReadCloser computes the digests of algorithms for rc.
+func NewHashReadCloser(rc io.ReadCloser, algorithms ...string) (*HashReadCloser, error) {
+	hs, w, err := newHashes(algorithms)
+	if err != nil {
+		return nil, err
+	}
+	return &HashReadCloser{ReadCloser: rc, Hashes: hs, w: w}, nil
+}
+
+func (hrc *HashReadCloser) Read(p []byte) (int, error) {
+	n, err := hrc.ReadCloser.Read(p)
+	hrc.w.Write(p[:n])
+	return n, err
+}
+
+func (hrc *HashReadCloser) Close() error {
+	return hrc.ReadCloser.Close()
+}
+
+// SumHex returns the hex digest of algorithm, or "" if it is not computed.
+func (hrc *HashReadCloser) SumHex(algorithm string) string {
+	return sumHex(hrc.Hashes, algorithm)
+}
+
+// HashWriteCloser implements io.WriteCloser.
+// It computes the digests of the data written to the underlying WriteCloser.
+type HashWriteCloser struct {
+	WriteCloser io.WriteCloser
+	Hashes      map[string]hash.Hash
+	w           io.Writer
+}
+
+// NewHashWriteCloser computes the digests of algorithms for wc.
+func NewHashWriteCloser(wc io.WriteCloser, algorithms ...string) (*HashWriteCloser, error) {
+	hs, w, err := newHashes(algorithms)
+	if err != nil {
+		return nil, err
+	}
+	return &HashWriteCloser{WriteCloser: wc, Hashes: hs, w: w}, nil
+}
+
+func (hwc *HashWriteCloser) Write(p []byte) (int, error) {
+	n, err := hwc.WriteCloser.Write(p)
+	hwc.w.Write(p[:n])
+	return n, err
+}
+
+func (hwc *HashWriteCloser) Close() error {
+	return hwc.WriteCloser.Close()
+}
+
+// SumHex returns the hex digest of algorithm, or "" if it is not computed.
+func (hwc *HashWriteCloser) SumHex(algorithm string) string {
+	return sumHex(hwc.Hashes, algorithm)
+}
+
+func sumHex(hs map[string]hash.Hash, algorithm string) string {
+	h, ok := hs[algorithm]
+	if !ok {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SidecarName returns the name of the file which has the SHA-256 digest of name.
+func SidecarName(name string) string {
+	return name + ".sha256"
+}
+
+// writeSidecar writes a digest in the format of sha256sum.
+func (o *Opener) writeSidecar(name, digest string) error {
+	line := fmt.Sprintf("%s  %s\n", digest, filepath.Base(name))
+	return afero.WriteFile(o.fs, SidecarName(name), []byte(line), 0644)
+}
+
+func (o *Opener) readSmallFile(name string) ([]byte, error) {
+	u, b, err := o.resolveURL(name)
+	if err != nil {
+		return nil, err
+	}
+	var rc io.ReadCloser
+	if b != nil {
+		rc, err = b.Open(o, u)
+	} else {
+		rc, err = o.fs.Open(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(io.LimitReader(rc, 1<<20))
+}
+
+// lookupDigest finds the SHA-256 digest of name in its sidecar, or SHA256SUMS in the same directory.
+func (o *Opener) lookupDigest(name string) (string, error) {
+	bs, err := o.readSmallFile(SidecarName(name))
+	if err == nil {
+		fields := strings.Fields(string(bs))
+		if len(fields) == 0 {
+			return "", fmt.Errorf("%s: invalid checksum file", SidecarName(name))
+		}
+		return fields[0], nil
+	}
+	if _, b, _ := o.resolveURL(name); b != nil || !os.IsNotExist(err) {
+		return "", err
+	}
+	manifest := filepath.Join(filepath.Dir(name), "SHA256SUMS")
+	bs, err = afero.ReadFile(o.fs, manifest)
+	if err != nil {
+		return "", fmt.Errorf("%s: no checksum: %w", name, err)
+	}
+	base := filepath.Base(name)
+	s := bufio.NewScanner(bytes.NewReader(bs))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		p := strings.TrimPrefix(fields[1], "*")
+		if p == base || filepath.Clean(p) == filepath.Clean(name) {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("%s: no checksum in %s", name, manifest)
+}
+
+// OpenVerified opens a file like Open, and Close fails with *ChecksumMismatchError if the SHA-256 digest of the file differs from digest.
+// If digest is empty, it is looked up in name.sha256 or SHA256SUMS in the same directory.
+// Close reads the rest of the file to compute the digest.
+func (o *Opener) OpenVerified(name, digest string) (io.ReadCloser, error) {
+	if digest == "" {
+		if o.shouldFallback(name) {
+			return nil, fmt.Errorf("no checksum for stdin")
+		}
+		var err error
+		digest, err = o.lookupDigest(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	o2 := *o
+	o2.Verify = false
+	o2.verifyDigest = strings.ToLower(digest)
+	return o2.Open(name)
+}
+
+func (o *Opener) verify(name string, rc io.ReadCloser) (io.ReadCloser, error) {
+	digest := o.verifyDigest
+	if digest == "" {
+		var err error
+		digest, err = o.lookupDigest(name)
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+	}
+	hrc, _ := NewHashReadCloser(rc, "sha256")
+	return ExtendReadCloser(hrc, func(_ io.ReadCloser) error {
+		_, err := io.Copy(ioutil.Discard, hrc)
+		err2 := hrc.Close()
+		if err != nil {
+			return err
+		}
+		if err2 != nil {
+			return err2
+		}
+		if actual := hrc.SumHex("sha256"); actual != strings.ToLower(digest) {
+			return &ChecksumMismatchError{Name: name, Algorithm: "sha256", Expected: digest, Actual: actual}
+		}
+		return nil
+	}), nil
+}
+
+// withSidecar writes the sidecar of name after wc is closed successfully.
+func (o *Opener) withSidecar(name string, wc io.WriteCloser) io.WriteCloser {
+	hwc, _ := NewHashWriteCloser(wc, "sha256")
+	return ExtendWriteCloser(hwc, func(_ io.WriteCloser) error {
+		err := hwc.Close()
+		if err != nil {
+			return err
+		}
+		return o.writeSidecar(name, hwc.SumHex("sha256"))
+	})
+}
+
+// CreateTempFileVerified is CreateTempFile which commits the file only if its SHA-256 digest equals digest.
+// Otherwise it fails with *ChecksumMismatchError.
+func (o *Opener) CreateTempFileVerified(dir, prefix, newname, digest string, handler func(f io.WriteCloser) (bool, error)) (bool, error) {
+	if o.shouldFallback(newname) {
+		return false, fmt.Errorf("can't verify stdout")
+	}
+	newname, err := o.resolveFilePath("create temp file", newname)
+	if err != nil {
+		return false, err
+	}
+	return o.createTempFile(dir, prefix, newname, strings.ToLower(digest), handler, nil)
+}
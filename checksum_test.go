@@ -0,0 +1,149 @@
+package ose_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+const (
+	fooSHA256 = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	barSHA256 = "fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9"
+)
+
+func TestHashWriteCloser(t *testing.T) {
+	sb := new(strings.Builder)
+	wc, err := ose.NewHashWriteCloser(ose.NopWriteCloser(sb), "sha256", "md5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "fo")
+	io.WriteString(wc, "o")
+	wc.Close()
+	if s := wc.SumHex("sha256"); s != fooSHA256 {
+		t.Fatalf("invalid digest: %s", s)
+	}
+	if s := wc.SumHex("md5"); s != "acbd18db4cc2f85cedef654fccc4a4d8" {
+		t.Fatalf("invalid digest: %s", s)
+	}
+	if s := wc.SumHex("sha1"); s != "" {
+		t.Fatalf("must not be computed: %s", s)
+	}
+	if _, err := ose.NewHashWriteCloser(ose.NopWriteCloser(sb), "crc"); err == nil {
+		t.Fatal("must fail")
+	}
+
+	rc, _ := ose.NewHashReadCloser(ioutil.NopCloser(strings.NewReader("foo")), "sha256")
+	ioutil.ReadAll(rc)
+	if s := rc.SumHex("sha256"); s != fooSHA256 {
+		t.Fatalf("invalid digest: %s", s)
+	}
+}
+
+func TestOpenerChecksumSidecar(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.ChecksumSidecar = true
+	wc, err := o.Create("dir/foo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "foo")
+	err = wc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := afero.ReadFile(w.FakeFs, "dir/foo.txt.sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != fooSHA256+"  foo.txt\n" {
+		t.Fatalf("invalid sidecar: %q", bs)
+	}
+
+	o = ose.NewOpenerInWorld(w)
+	o.Verify = true
+	rc, err := o.Open("dir/foo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	afero.WriteFile(w.FakeFs, "dir/foo.txt", []byte("bar"), 0644)
+	rc, err = o.Open("dir/foo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(rc)
+	err = rc.Close()
+	var merr *ose.ChecksumMismatchError
+	if !errors.As(err, &merr) || merr.Expected != fooSHA256 || merr.Actual != barSHA256 {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
+
+func TestOpenerVerifyManifest(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "/dir/foo.txt.gz", nil, 0644)
+	afero.WriteFile(w.FakeFs, "/dir/bar.txt", []byte("bar"), 0644)
+	afero.WriteFile(w.FakeFs, "/dir/SHA256SUMS", []byte(fooSHA256+"  foo.txt\n"+barSHA256+" *bar.txt\n"), 0644)
+	o := ose.NewOpenerInWorld(w)
+	rc, err := o.OpenVerified("/dir/bar.txt", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.OpenVerified("/dir/foo.txt.gz", ""); err == nil {
+		t.Fatal("must fail without checksums")
+	}
+
+	w.FakeIO.WriteInString("bar")
+	rc, err = o.OpenVerified("-", strings.ToUpper(fooSHA256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var merr *ose.ChecksumMismatchError
+	if err := rc.Close(); !errors.As(err, &merr) {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
+
+func TestOpenerCreateTempFileVerified(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	write := func(s string) func(f io.WriteCloser) (bool, error) {
+		return func(f io.WriteCloser) (bool, error) {
+			_, err := io.WriteString(f, s)
+			return true, err
+		}
+	}
+	_, err := o.CreateTempFileVerified("", "opener", "foo.txt", fooSHA256, write("bar"))
+	var merr *ose.ChecksumMismatchError
+	if !errors.As(err, &merr) {
+		t.Fatalf("invalid error: %v", err)
+	}
+	if ose.Exists(w.FakeFs, "foo.txt") {
+		t.Fatal("a corrupt file must not be committed")
+	}
+	o.ChecksumSidecar = true
+	ok, err := o.CreateTempFileVerified("", "opener", "foo.txt", fooSHA256, write("foo"))
+	if err != nil || !ok {
+		t.Fatalf("must be ok: %v", err)
+	}
+	bs, _ := afero.ReadFile(w.FakeFs, "foo.txt")
+	if string(bs) != "foo" {
+		t.Fatalf("invalid content: %q", bs)
+	}
+	if !ose.Exists(w.FakeFs, "foo.txt.sha256") {
+		t.Fatal("sidecar must be written")
+	}
+}
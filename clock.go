@@ -0,0 +1,247 @@
+package ose
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// see https://stackoverflow.com/questions/18970265
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is the Clock counterpart of time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is the Clock counterpart of time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) NewTimer(d time.Duration) Timer         { return realTimer{time.NewTimer(d)} }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time        { return t.t.C }
+func (t realTimer) Stop() bool                 { return t.t.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// FakeClock is a virtual Clock.
+// Now returns Time and steps forward by Duration on every call.
+// Timers, tickers and sleepers never fire by themselves; they fire only when Advance moves the time past their deadlines.
+// Time, Duration and Count are not synchronized, so use GetCount and SetCount while other goroutines use the clock.
+type FakeClock struct {
+	Time     time.Time
+	Duration time.Duration
+	// Count is the number of calls of Now.
+	Count  int64
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	timers []*fakeTimer
+}
+
+func NewFakeClock(t time.Time, d time.Duration) *FakeClock {
+	return &FakeClock{Time: t, Duration: d}
+}
+
+func (c *FakeClock) current() time.Time {
+	return c.Time.Add(time.Duration(c.Count) * c.Duration)
+}
+
+func (c *FakeClock) condition() *sync.Cond {
+	if c.cond == nil {
+		c.cond = sync.NewCond(&c.mutex)
+	}
+	return c.cond
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	t := c.current()
+	c.Count++
+	return t
+}
+
+// GetCount returns Count.
+func (c *FakeClock) GetCount() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.Count
+}
+
+// SetCount sets Count.
+func (c *FakeClock) SetCount(n int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Count = n
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time { return c.NewTimer(d).C() }
+
+// Sleep blocks until another goroutine advances the clock by d.
+func (c *FakeClock) Sleep(d time.Duration) { <-c.After(d) }
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1), period: d}
+	t.Reset(d)
+	return fakeTicker{t}
+}
+
+// AfterFunc calls f synchronously in the goroutine that advances the clock past the deadline.
+// The returned Timer has a nil channel, like the one of time.AfterFunc.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &fakeTimer{clock: c, f: f}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d and fires exactly the timers whose deadlines have passed.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	c.Time = c.Time.Add(d)
+	fs := c.fire()
+	c.mutex.Unlock()
+	for _, f := range fs {
+		f()
+	}
+}
+
+// BlockUntil blocks until at least n timers, tickers or sleepers are waiting on the clock.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for len(c.timers) < n {
+		c.condition().Wait()
+	}
+}
+
+// Waiters returns the number of timers, tickers and sleepers waiting on the clock.
+func (c *FakeClock) Waiters() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.timers)
+}
+
+func (c *FakeClock) fire() []func() {
+	now := c.current()
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	fs := make([]func(), 0)
+	for _, t := range c.timers {
+		if t.deadline.After(now) {
+			break
+		}
+		switch {
+		case t.f != nil:
+			fs = append(fs, t.f)
+			t.active = false
+		case t.period > 0:
+			for !t.deadline.After(now) {
+				t.send(t.deadline)
+				t.deadline = t.deadline.Add(t.period)
+			}
+		default:
+			t.send(t.deadline)
+			t.active = false
+		}
+	}
+	c.prune()
+	return fs
+}
+
+func (c *FakeClock) prune() {
+	timers := make([]*fakeTimer, 0, len(c.timers))
+	for _, t := range c.timers {
+		if t.active {
+			timers = append(timers, t)
+		}
+	}
+	c.timers = timers
+	c.condition().Broadcast()
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	f        func()
+	period   time.Duration
+	deadline time.Time
+	active   bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) send(v time.Time) {
+	select {
+	case t.c <- v:
+	default:
+	}
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	active := t.active
+	t.active = false
+	c.prune()
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mutex.Lock()
+	active := t.active
+	t.deadline = c.current().Add(d)
+	if !active {
+		t.active = true
+		c.timers = append(c.timers, t)
+	}
+	fs := c.fire()
+	c.mutex.Unlock()
+	for _, f := range fs {
+		f()
+	}
+	return active
+}
+
+type fakeTicker struct{ t *fakeTimer }
+
+func (t fakeTicker) C() <-chan time.Time { return t.t.C() }
+func (t fakeTicker) Stop()               { t.t.Stop() }
@@ -0,0 +1,129 @@
+package ose_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/taskie/ose"
+)
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeClockNow(t *testing.T) {
+	c := ose.NewFakeClock(epoch, time.Second)
+	if now := c.Now(); !now.Equal(epoch) {
+		t.Fatalf("invalid time: %v", now)
+	}
+	if now := c.Now(); !now.Equal(epoch.Add(time.Second)) {
+		t.Fatalf("invalid time: %v", now)
+	}
+	c.Advance(time.Minute)
+	if now := c.Now(); !now.Equal(epoch.Add(time.Minute + 2*time.Second)) {
+		t.Fatalf("invalid time: %v", now)
+	}
+}
+
+func TestFakeClockAfter(t *testing.T) {
+	c := ose.NewFakeClock(epoch, 0)
+	ch := c.After(time.Second)
+	c.Advance(999 * time.Millisecond)
+	select {
+	case <-ch:
+		t.Fatal("must not fire")
+	default:
+	}
+	c.Advance(time.Millisecond)
+	select {
+	case v := <-ch:
+		if !v.Equal(epoch.Add(time.Second)) {
+			t.Fatalf("invalid time: %v", v)
+		}
+	default:
+		t.Fatal("must fire")
+	}
+	if c.Waiters() != 0 {
+		t.Fatalf("invalid waiters: %d", c.Waiters())
+	}
+}
+
+func TestFakeClockTimer(t *testing.T) {
+	c := ose.NewFakeClock(epoch, 0)
+	timer := c.NewTimer(time.Second)
+	if !timer.Stop() {
+		t.Fatal("must be active")
+	}
+	c.Advance(time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("must not fire")
+	default:
+	}
+	if timer.Reset(time.Second) {
+		t.Fatal("must not be active")
+	}
+	c.Advance(time.Second)
+	select {
+	case <-timer.C():
+	default:
+		t.Fatal("must fire")
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	c := ose.NewFakeClock(epoch, 0)
+	ticker := c.NewTicker(time.Second)
+	defer ticker.Stop()
+	for i := 1; i <= 3; i++ {
+		c.Advance(time.Second)
+		v := <-ticker.C()
+		if !v.Equal(epoch.Add(time.Duration(i) * time.Second)) {
+			t.Fatalf("invalid time: %v", v)
+		}
+	}
+	if c.Waiters() != 1 {
+		t.Fatalf("invalid waiters: %d", c.Waiters())
+	}
+}
+
+func TestFakeClockAfterFunc(t *testing.T) {
+	c := ose.NewFakeClock(epoch, 0)
+	called := 0
+	c.AfterFunc(time.Second, func() { called++ })
+	c.Advance(time.Second)
+	c.Advance(time.Second)
+	if called != 1 {
+		t.Fatalf("invalid count: %d", called)
+	}
+}
+
+func TestFakeClockSleep(t *testing.T) {
+	c := ose.NewFakeClock(epoch, 0)
+	done := make(chan struct{})
+	go func() {
+		c.Sleep(time.Hour)
+		close(done)
+	}()
+	c.BlockUntil(1)
+	c.Advance(time.Hour)
+	<-done
+}
+
+func TestFakeClockConcurrency(t *testing.T) {
+	c := ose.NewFakeClock(epoch, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Sleep(time.Second)
+			_ = c.Now()
+		}()
+	}
+	c.BlockUntil(10)
+	c.Advance(time.Second)
+	wg.Wait()
+	if c.Waiters() != 0 {
+		t.Fatalf("invalid waiters: %d", c.Waiters())
+	}
+}
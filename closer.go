@@ -68,6 +68,45 @@ func NopWriteCloser(w io.Writer) *ComposedWriteCloser {
 	return NewWriteCloser(w, nopWriteCloseFunc)
 }
 
+// ReadSeekCloser is the interface that groups the basic Read, Seek and Close methods.
+type ReadSeekCloser interface {
+	io.Reader
+	io.Seeker
+	io.Closer
+}
+
+// ComposedReadSeekCloser implements ReadSeekCloser.
+// It has an underlying ReadSeeker and a function that is called when Close method is called.
+type ComposedReadSeekCloser struct {
+	ReadSeeker io.ReadSeeker
+	CloseFunc  func(rs io.ReadSeeker) error
+}
+
+// NewReadSeekCloser composes a ReadSeekCloser with an underlying ReadSeeker and a function that is called when Close method is called.
+func NewReadSeekCloser(rs io.ReadSeeker, closeFunc func(rs io.ReadSeeker) error) *ComposedReadSeekCloser {
+	return &ComposedReadSeekCloser{
+		ReadSeeker: rs,
+		CloseFunc:  closeFunc,
+	}
+}
+
+func (crsc *ComposedReadSeekCloser) Read(p []byte) (int, error) {
+	return crsc.ReadSeeker.Read(p)
+}
+
+func (crsc *ComposedReadSeekCloser) Seek(offset int64, whence int) (int64, error) {
+	return crsc.ReadSeeker.Seek(offset, whence)
+}
+
+func (crsc *ComposedReadSeekCloser) Close() error {
+	return crsc.CloseFunc(crsc.ReadSeeker)
+}
+
+// NopReadSeekCloser composes a ReadSeekCloser with an underlying ReadSeeker. It does nothing when Close method is called.
+func NopReadSeekCloser(rs io.ReadSeeker) *ComposedReadSeekCloser {
+	return NewReadSeekCloser(rs, func(_ io.ReadSeeker) error { return nil })
+}
+
 // ExtendedReadCloser implements io.ReadCloser.
 // It has an underlying Reader and a function that is called when Close method is called.
 type ExtendedReadCloser struct {
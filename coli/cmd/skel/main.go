@@ -1,7 +1,12 @@
 package main
 
 import (
+	"errors"
+	"io"
+	"path/filepath"
+
 	"github.com/spf13/cobra"
+	"github.com/taskie/ose"
 	"github.com/taskie/ose/coli"
 	"go.uber.org/zap"
 )
@@ -20,10 +25,13 @@ func main() {
 
 func NewCommand(cl *coli.Coli) *cobra.Command {
 	cmd := &cobra.Command{
-		Use: CommandName,
-		Run: cl.WrapRun(run),
+		Use:   CommandName + " [INPUT [OUTPUT]]",
+		Short: "convert data between JSON, YAML, TOML, CSV and NDJSON",
+		Args:  cobra.MaximumNArgs(2),
+		Run:   cl.WrapRun(run),
 	}
 	cl.Prepare(cmd)
+	cl.PrepareWriteFlags(cmd)
 
 	cmd.Flags().StringP("from-type", "f", "", "convert from")
 	cmd.Flags().StringP("to-type", "t", "", "convert to")
@@ -34,11 +42,86 @@ func NewCommand(cl *coli.Coli) *cobra.Command {
 
 func run(cl *coli.Coli, cmd *cobra.Command, args []string) {
 	v := cl.Viper()
-	m := make(map[string]interface{})
-	err := v.Unmarshal(&m)
+	input, output := "-", "-"
+	if len(args) >= 1 {
+		input = args[0]
+	}
+	if len(args) >= 2 {
+		output = args[1]
+	}
+	src := ose.NewOpenerWithContext(cmd.Context())
+	src.AutoCompression = true
+	src.DataFormat = v.GetString("from_type")
+	dst := *src
+	err := cl.ConfigureOpener(&dst)
+	if err != nil {
+		zap.L().Fatal("invalid flags", zap.Error(err))
+	}
+	dst.DataFormat = v.GetString("to_type")
+	if dst.DataFormat == "" {
+		f, err := src.FileDataFormat(input)
+		if err != nil {
+			zap.L().Fatal("unknown input format", zap.Error(err))
+		}
+		dst.DataFormat = f.Name
+	}
+	err = convertRecords(src, &dst, input, output)
+	if err == errNotRecords {
+		err = convert(src, &dst, input, output)
+	}
+	if err != nil {
+		zap.L().Fatal("can't convert", zap.Error(err))
+	}
+}
+
+func convert(src, dst *ose.Opener, input, output string) error {
+	var data interface{}
+	err := src.DecodeFile(input, &data)
+	if err != nil {
+		return err
+	}
+	return dst.EncodeFile(output, data)
+}
+
+var errNotRecords = errors.New("not records")
+
+// convertRecords streams records if both of the formats support it.
+func convertRecords(src, dst *ose.Opener, input, output string) error {
+	from, err := src.FileDataFormat(input)
+	if err != nil {
+		return err
+	}
+	to, err := dst.FileDataFormat(output)
+	if err != nil {
+		return err
+	}
+	if from.NewRecordReader == nil || to.NewRecordWriter == nil {
+		return errNotRecords
+	}
+	rr, err := src.OpenRecords(input)
 	if err != nil {
-		zap.L().Fatal("can't unmarshal", zap.Error(err))
+		return err
 	}
-	cmd.Printf("flags: %v\n", m)
-	cmd.Printf("args: %v\n", args)
+	defer rr.Close()
+	// the output is committed only if every record is converted
+	_, err = dst.CreateTempFile(filepath.Dir(output), "."+CommandName+"-", output, func(wc io.WriteCloser) (bool, error) {
+		rw := to.NewRecordWriter(wc)
+		for {
+			var r interface{}
+			err := rr.Read(&r)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return false, err
+			}
+			err = rw.Write(r)
+			if err != nil {
+				return false, err
+			}
+		}
+		err := rw.Flush()
+		return err == nil, err
+	})
+	return err
 }
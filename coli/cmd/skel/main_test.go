@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/taskie/ose"
+	"github.com/taskie/ose/coli"
+	"github.com/taskie/ose/coli/colitest"
+)
+
+func TestScripts(t *testing.T) {
+	colitest.Run(t, colitest.Params{
+		Commands: map[string]coli.ColiCommandProducer{
+			"skel": func(cl *coli.Coli, name string, path []string) *cobra.Command {
+				return NewCommand(cl)
+			},
+		},
+	})
+}
+
+func TestConvertRecordsError(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "users.ndjson", []byte("{\"id\":\"1\"}\n{broken\n"), 0644)
+	afero.WriteFile(w.FakeFs, "old.csv", []byte("old\n"), 0644)
+	o := ose.NewOpenerInWorld(w)
+	for _, output := range []string{"old.csv", "new.csv"} {
+		err := convertRecords(o, o, "users.ndjson", output)
+		if err == nil {
+			t.Fatal("must fail")
+		}
+	}
+	bs, _ := afero.ReadFile(w.FakeFs, "old.csv")
+	if string(bs) != "old\n" {
+		t.Fatalf("invalid content: %q", bs)
+	}
+	if ok, _ := afero.Exists(w.FakeFs, "new.csv"); ok {
+		t.Fatal("must not commit partial output")
+	}
+}
@@ -1,7 +1,9 @@
 package coli
 
 import (
+	"context"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/iancoleman/strcase"
@@ -15,22 +17,37 @@ import (
 )
 
 type Coli struct {
-	fs  afero.Fs
-	io  ose.IO
-	vpr *viper.Viper
+	fs        afero.Fs
+	io        ose.IO
+	env       ose.Env
+	vpr       *viper.Viper
+	envPrefix string
+	workDir   string
 }
 
 func NewColi(fs afero.Fs, oio ose.IO, vpr *viper.Viper) *Coli {
-	return &Coli{fs: fs, io: oio, vpr: vpr}
+	return &Coli{fs: fs, io: oio, env: ose.GetEnv(), vpr: vpr, workDir: "."}
+}
+
+func NewColiInWorld(w ose.World) *Coli {
+	return &Coli{fs: w.Fs(), io: w.IO(), env: w.Env(), vpr: viper.New(), workDir: "."}
 }
 
 func NewColiInThisWorld() *Coli {
-	w := ose.GetWorld()
-	return NewColi(w.Fs(), w.IO(), viper.New())
+	return NewColiInWorld(ose.GetWorld())
+}
+
+// NewColiWithContext creates a Coli in the World carried by ctx.
+func NewColiWithContext(ctx context.Context) *Coli {
+	return NewColiInWorld(ose.WorldFrom(ctx))
 }
 
 func (c *Coli) Viper() *viper.Viper { return c.vpr }
 
+// SetWorkDir sets the directory where PrepareConfig looks for config files instead of ".".
+// It must be called before PrepareConfig.
+func (c *Coli) SetWorkDir(dir string) { c.workDir = dir }
+
 func (c *Coli) Prepare(cmd *cobra.Command) {
 	c.PrepareIO(cmd)
 	c.PrepareFs(cmd)
@@ -57,16 +74,49 @@ func (c *Coli) PrepareWellKnownFlags(cmd *cobra.Command) {
 	c.BindFlags(flg, []string{"verbose", "debug"})
 }
 
+// PrepareColorFlags adds --color=auto|always|never, which is applied to the IO in PreRun.
+func (c *Coli) PrepareColorFlags(cmd *cobra.Command) {
+	flg := cmd.PersistentFlags()
+	flg.String("color", "auto", "colorize output (auto, always or never)")
+	c.BindFlags(flg, []string{"color"})
+}
+
+// PrepareWriteFlags adds --no-clobber, --backup[=MODE], --suffix and --append, which are applied to an Opener by ConfigureOpener.
+func (c *Coli) PrepareWriteFlags(cmd *cobra.Command) {
+	flg := cmd.PersistentFlags()
+	flg.Bool("no-clobber", false, "do not overwrite an existing file")
+	flg.String("backup", "none", "make a backup of each existing file (none, simple, numbered or existing)")
+	flg.Lookup("backup").NoOptDefVal = "existing"
+	flg.StringP("suffix", "S", ose.DefaultBackupSuffix, "the suffix of simple backups")
+	flg.Bool("append", false, "append to an existing file")
+	c.BindFlags(flg, []string{"no-clobber", "backup", "suffix", "append"})
+}
+
+// ConfigureOpener applies the flags added by PrepareWriteFlags to o.
+func (c *Coli) ConfigureOpener(o *ose.Opener) error {
+	v := c.vpr
+	mode, err := ose.ParseBackupMode(v.GetString("backup"))
+	if err != nil {
+		return err
+	}
+	o.NoClobber = v.GetBool("no_clobber")
+	o.Backup = mode
+	o.BackupSuffix = v.GetString("suffix")
+	o.Append = v.GetBool("append")
+	return nil
+}
+
 func (c *Coli) PrepareConfig(cmd *cobra.Command) {
 	v := c.vpr
 	name := cmd.Use
 	v.SetConfigName(name)
-	v.AddConfigPath(".")
-	configHome, err := ose.NewEnvPath(c.fs, ose.GetEnv()).GetXdgConfigHome()
+	v.AddConfigPath(c.workDir)
+	configHome, err := ose.NewEnvPath(c.fs, c.env).GetXdgConfigHome()
 	if err == nil {
 		v.AddConfigPath(filepath.Join(configHome, name))
 	}
 	v.SetEnvPrefix(name)
+	c.envPrefix = name
 }
 
 func (c *Coli) PreparePreRun(cmd *cobra.Command) {
@@ -124,11 +174,19 @@ func newDebugLogger() *zap.Logger {
 
 func (c *Coli) PreRun(cmd *cobra.Command, args []string) {
 	v := c.Viper()
-	v.AutomaticEnv()
 	err := v.ReadInConfig()
 	if err != nil {
 		zap.L().Debug("can't read in config", zap.Error(err))
 	}
+	c.bindEnv(cmd)
+	if v.IsSet("color") {
+		mode, err := ose.ParseColorMode(v.GetString("color"))
+		if err != nil {
+			zap.L().Warn("invalid color mode", zap.Error(err))
+		} else {
+			ose.SetColorMode(c.io, mode)
+		}
+	}
 	if v.GetBool("verbose") {
 		zap.ReplaceGlobals(newVerboseLogger())
 	} else if v.GetBool("debug") {
@@ -138,10 +196,54 @@ func (c *Coli) PreRun(cmd *cobra.Command, args []string) {
 	}
 }
 
+// bindEnv makes viper read environment variables from the Env of the Coli like AutomaticEnv.
+// viper can only read the real ones, so the variables of the others named by the env prefix and upper-cased keys are set into viper unless the flags are changed.
+// Empty values are ignored as AutomaticEnv does.
+func (c *Coli) bindEnv(cmd *cobra.Command) {
+	v := c.Viper()
+	switch c.env.(type) {
+	case nil, ose.RealEnv:
+		v.AutomaticEnv()
+		return
+	}
+	changed := make(map[string]bool)
+	visit := func(f *pflag.Flag) {
+		if f.Changed {
+			changed[strcase.ToSnake(f.Name)] = true
+		}
+	}
+	cmd.Flags().VisitAll(visit)
+	cmd.InheritedFlags().VisitAll(visit)
+	prefix := ""
+	if c.envPrefix != "" {
+		prefix = strings.ToUpper(c.envPrefix) + "_"
+	}
+	for _, kv := range c.env.Environ() {
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			continue
+		}
+		envKey, val := kv[:i], kv[i+1:]
+		if val == "" || !strings.HasPrefix(envKey, prefix) {
+			continue
+		}
+		key := strings.ToLower(envKey[len(prefix):])
+		if key == "" || strings.ToUpper(key) != envKey[len(prefix):] || changed[key] {
+			continue
+		}
+		v.Set(key, val)
+	}
+}
+
 func (c *Coli) Execute(cmd *cobra.Command) error {
 	return cmd.Execute()
 }
 
+// ExecuteContext executes cmd with ctx, which can be obtained by cmd.Context() in Run.
+func (c *Coli) ExecuteContext(ctx context.Context, cmd *cobra.Command) error {
+	return cmd.ExecuteContext(ctx)
+}
+
 func (c *Coli) WrapRun(f func(c *Coli, cmd *cobra.Command, args []string)) func(cmd *cobra.Command, args []string) {
 	return func(cmd *cobra.Command, args []string) {
 		f(c, cmd, args)
@@ -2,10 +2,13 @@ package coli_test
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io/ioutil"
 	"testing"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/taskie/ose"
 	"github.com/taskie/ose/coli"
 )
@@ -46,3 +49,113 @@ func TestColi(t *testing.T) {
 		t.Fatalf("invalid content: %v", actualErr)
 	}
 }
+
+func TestColiWithContext(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		i := i
+		t.Run(fmt.Sprintf("world%d", i), func(t *testing.T) {
+			t.Parallel()
+			w := ose.NewFakeWorld()
+			ctx := ose.WithWorld(context.Background(), w)
+			cl := coli.NewColiWithContext(ctx)
+			cmd := &cobra.Command{
+				Use: "test",
+				Run: func(cmd *cobra.Command, args []string) {
+					o := ose.NewOpenerWithContext(cmd.Context())
+					wc, err := o.Create("-")
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer wc.Close()
+					fmt.Fprintf(wc, "out%d", i)
+				},
+			}
+			cl.Prepare(cmd)
+			cmd.SetArgs([]string{})
+			err := cl.ExecuteContext(ctx, cmd)
+			if err != nil {
+				t.Fatalf("some error occured (execute): %v", err)
+			}
+			expected := fmt.Sprintf("out%d", i)
+			if w.FakeIO.OutBuf.String() != expected {
+				t.Fatalf("invalid content: %v", w.FakeIO.OutBuf.String())
+			}
+		})
+	}
+}
+
+func TestColiWithRecordingIO(t *testing.T) {
+	w := ose.NewFakeWorld()
+	rio := ose.NewRecordingIOInWorld(w)
+	cl := coli.NewColi(w.Fs(), rio, viper.New())
+	cmd := &cobra.Command{
+		Use: "test",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Println("out")
+			cmd.PrintErr("err\n")
+			cmd.Println("out")
+		},
+	}
+	cl.Prepare(cmd)
+	cmd.SetArgs([]string{})
+	err := cl.Execute(cmd)
+	if err != nil {
+		t.Fatalf("some error occured (execute): %v", err)
+	}
+	expected := "out| out\nerr| err\nout| out\n"
+	if s := rio.Render(false); s != expected {
+		t.Fatalf("invalid transcript: %q", s)
+	}
+}
+
+func TestColiColorFlags(t *testing.T) {
+	w := ose.NewFakeWorld()
+	cl := coli.NewColiInWorld(w)
+	enabled := false
+	cmd := &cobra.Command{
+		Use: "test",
+		Run: func(cmd *cobra.Command, args []string) {
+			enabled = ose.ColorEnabled(w.IO(), ose.StreamOut)
+		},
+	}
+	cl.Prepare(cmd)
+	cl.PrepareColorFlags(cmd)
+	cmd.SetArgs([]string{"--color=always"})
+	err := cl.Execute(cmd)
+	if err != nil {
+		t.Fatalf("some error occured (execute): %v", err)
+	}
+	if !enabled {
+		t.Fatal("color must be enabled")
+	}
+	cmd.SetArgs([]string{"--color=bogus"})
+	err = cl.Execute(cmd)
+	if err != nil {
+		t.Fatalf("some error occured (execute): %v", err)
+	}
+	if w.FakeIO.ColorMode != ose.ColorAlways {
+		t.Fatalf("an invalid mode must be ignored: %v", w.FakeIO.ColorMode)
+	}
+}
+
+func TestColiWriteFlags(t *testing.T) {
+	w := ose.NewFakeWorld()
+	cl := coli.NewColiInWorld(w)
+	o := ose.NewOpenerInWorld(w)
+	cmd := &cobra.Command{
+		Use: "test",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cl.ConfigureOpener(o)
+		},
+	}
+	cl.Prepare(cmd)
+	cl.PrepareWriteFlags(cmd)
+	cmd.SetArgs([]string{"--no-clobber", "--backup", "-S", ".bak", "--append"})
+	err := cl.Execute(cmd)
+	if err != nil {
+		t.Fatalf("some error occured (execute): %v", err)
+	}
+	if !o.NoClobber || o.Backup != ose.BackupExisting || o.BackupSuffix != ".bak" || !o.Append {
+		t.Fatalf("invalid opener: %v %v %q %v", o.NoClobber, o.Backup, o.BackupSuffix, o.Append)
+	}
+}
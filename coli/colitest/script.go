@@ -0,0 +1,396 @@
+// Package colitest runs scripts which test coli commands in a FakeWorld, in the style of testscript.
+//
+// A script is a txtar archive whose comment is a list of commands and whose files are created in the FakeWorld:
+//
+//	env GREETING=hello
+//	exec greet --name world
+//	stdout '^hello, world$'
+//	! stderr .
+//	cmp out.txt want.txt
+//	-- want.txt --
+//	hello
+//
+// Relative paths are resolved against $WORK, a directory only in the fake filesystem,
+// and config files are looked up there instead of ".". Nothing is written to the real filesystem.
+//
+// The harness doesn't call Coli.Prepare. Wrap producers of commands which don't prepare themselves with Prepared.
+//
+// Each line is a command and its arguments separated by spaces.
+// Arguments may be quoted by single quotes, and $VAR or ${VAR} outside quotes is expanded by the Env of the world.
+// A command prefixed with "!" is expected to fail.
+//
+// The commands are:
+//
+//	exec name [args...]  run the command produced for name in-process
+//	stdin file           use file as the standard input of the next exec
+//	stdout regexp        match the standard output of the last exec
+//	stderr regexp        match the standard error of the last exec
+//	cmp file1 file2      compare files, where stdout and stderr mean the outputs of the last exec
+//	env [key=value...]   set environment variables, or print them into the log
+//	exists path...       check that files exist
+//	mkdir path...        create directories
+//	rm path...           remove files or directories
+package colitest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/taskie/ose"
+	"github.com/taskie/ose/coli"
+	"golang.org/x/tools/txtar"
+)
+
+// Params configures Run.
+type Params struct {
+	// Dir is the directory of scripts (*.txtar). The default is testdata/script.
+	Dir string
+	// Commands maps names used by exec to the producers of the commands.
+	Commands map[string]coli.ColiCommandProducer
+	// Setup is called with a new FakeWorld before each script.
+	Setup func(w *ose.FakeWorld) error
+}
+
+// WorkDir is the working directory of scripts in the fake filesystem, which is also set to $WORK.
+var WorkDir = filepath.FromSlash("/work")
+
+// Prepared wraps prod so that the produced command is prepared by Coli.Prepare.
+func Prepared(prod coli.ColiCommandProducer) coli.ColiCommandProducer {
+	return func(cl *coli.Coli, name string, path []string) *cobra.Command {
+		cmd := prod(cl, name, path)
+		cl.Prepare(cmd)
+		return cmd
+	}
+}
+
+// Run runs every script in p.Dir as a subtest.
+func Run(t *testing.T, p Params) {
+	t.Helper()
+	dir := p.Dir
+	if dir == "" {
+		dir = filepath.Join("testdata", "script")
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.txtar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no scripts in %s", dir)
+	}
+	for _, file := range files {
+		file := file
+		name := strings.TrimSuffix(filepath.Base(file), ".txtar")
+		t.Run(name, func(t *testing.T) {
+			RunFile(t, p, file)
+		})
+	}
+}
+
+// RunFile runs the script in the file.
+func RunFile(t *testing.T, p Params, file string) {
+	t.Helper()
+	bs, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RunScript(t, p, file, bs)
+}
+
+// RunScript runs the script data. name is used in error messages.
+func RunScript(t *testing.T, p Params, name string, data []byte) {
+	t.Helper()
+	w := ose.NewFakeWorld()
+	if err := w.FakeFs.MkdirAll(WorkDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	w.FakeFs = &workFs{fs: w.FakeFs, dir: WorkDir}
+	_ = w.FakeEnv.Set("WORK", WorkDir)
+	a := txtar.Parse(data)
+	for _, f := range a.Files {
+		if err := afero.WriteFile(w.FakeFs, f.Name, f.Data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.Setup != nil {
+		if err := p.Setup(w); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &state{t: t, params: p, world: w}
+	for i, line := range strings.Split(string(a.Comment), "\n") {
+		s.lineno = i + 1
+		if err := s.runLine(line); err != nil {
+			t.Fatalf("%s:%d: %s: %v", name, s.lineno, strings.TrimSpace(line), err)
+		}
+	}
+}
+
+type state struct {
+	t      *testing.T
+	params Params
+	world  *ose.FakeWorld
+	lineno int
+	stdin  []byte
+	stdout string
+	stderr string
+}
+
+func (s *state) runLine(line string) error {
+	args, err := s.parse(line)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	neg := false
+	if args[0] == "!" {
+		neg = true
+		args = args[1:]
+		if len(args) == 0 {
+			return fmt.Errorf("missing command after !")
+		}
+	}
+	switch args[0] {
+	case "exec":
+		return s.exec(neg, args[1:])
+	case "stdin":
+		return s.noNeg(neg, args[0], s.setStdin, args[1:])
+	case "stdout":
+		return s.match(neg, args[0], s.stdout, args[1:])
+	case "stderr":
+		return s.match(neg, args[0], s.stderr, args[1:])
+	case "cmp":
+		return s.cmp(neg, args[1:])
+	case "env":
+		return s.noNeg(neg, args[0], s.env, args[1:])
+	case "exists":
+		return s.exists(neg, args[1:])
+	case "mkdir":
+		return s.noNeg(neg, args[0], s.mkdir, args[1:])
+	case "rm":
+		return s.noNeg(neg, args[0], s.rm, args[1:])
+	}
+	return fmt.Errorf("unknown command: %s", args[0])
+}
+
+// parse splits a line into arguments, where a comment starts with "#".
+func (s *state) parse(line string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg, quoted := false, false
+	rs := []rune(line)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case quoted:
+			if r == '\'' {
+				if i+1 < len(rs) && rs[i+1] == '\'' {
+					arg.WriteRune('\'')
+					i++
+				} else {
+					quoted = false
+				}
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'':
+			inArg, quoted = true, true
+		case r == '#' && !inArg:
+			i = len(rs)
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			j := i
+			for j < len(rs) && rs[j] != '\'' && !unicode.IsSpace(rs[j]) {
+				j++
+			}
+			arg.WriteString(s.world.FakeEnv.Expand(string(rs[i:j])))
+			inArg = true
+			i = j - 1
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+func (s *state) noNeg(neg bool, name string, f func(args []string) error, args []string) error {
+	if neg {
+		return fmt.Errorf("%s does not support !", name)
+	}
+	return f(args)
+}
+
+func (s *state) exec(neg bool, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: exec name [args...]")
+	}
+	prod, ok := s.params.Commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown program: %s", args[0])
+	}
+	fio := s.world.FakeIO
+	fio.WithLock(func() {
+		fio.InBuf.Reset()
+		fio.OutBuf.Reset()
+		fio.ErrBuf.Reset()
+		fio.InBuf.Write(s.stdin)
+	})
+	s.stdin = nil
+
+	ctx := ose.WithWorld(context.Background(), s.world)
+	cl := coli.NewColiWithContext(ctx)
+	cl.SetWorkDir(WorkDir)
+	cmd := prod(cl, args[0], []string{args[0]})
+	cmd.SetArgs(args[1:])
+	err := cl.ExecuteContext(ctx, cmd)
+
+	s.stdout = fio.OutString()
+	s.stderr = fio.ErrString()
+	if s.stdout != "" {
+		s.t.Logf("[stdout]\n%s", s.stdout)
+	}
+	if s.stderr != "" {
+		s.t.Logf("[stderr]\n%s", s.stderr)
+	}
+	if err != nil && !neg {
+		return fmt.Errorf("unexpected failure: %w", err)
+	}
+	if err == nil && neg {
+		return fmt.Errorf("unexpected success")
+	}
+	return nil
+}
+
+func (s *state) setStdin(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: stdin file")
+	}
+	bs, err := s.read(args[0])
+	if err != nil {
+		return err
+	}
+	s.stdin = bs
+	return nil
+}
+
+func (s *state) match(neg bool, name string, text string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s regexp", name)
+	}
+	re, err := regexp.Compile("(?m)" + args[0])
+	if err != nil {
+		return err
+	}
+	matched := re.MatchString(text)
+	if matched && neg {
+		return fmt.Errorf("unexpected match for %#q in %s", args[0], name)
+	}
+	if !matched && !neg {
+		return fmt.Errorf("no match for %#q in %s", args[0], name)
+	}
+	return nil
+}
+
+func (s *state) read(name string) ([]byte, error) {
+	switch name {
+	case "stdout":
+		return []byte(s.stdout), nil
+	case "stderr":
+		return []byte(s.stderr), nil
+	}
+	return afero.ReadFile(s.world.FakeFs, name)
+}
+
+func (s *state) cmp(neg bool, args []string) error {
+	if neg {
+		return fmt.Errorf("cmp does not support !")
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: cmp file1 file2")
+	}
+	a, err := s.read(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := s.read(args[1])
+	if err != nil {
+		return err
+	}
+	if string(a) != string(b) {
+		return fmt.Errorf("%s and %s differ:\n%s", args[0], args[1], ose.DiffLines(string(a), string(b)))
+	}
+	return nil
+}
+
+func (s *state) env(args []string) error {
+	if len(args) == 0 {
+		s.t.Logf("[env]\n%s", strings.Join(s.world.FakeEnv.Environ(), "\n"))
+		return nil
+	}
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			s.t.Logf("%s=%s", arg, s.world.FakeEnv.Get(arg))
+			continue
+		}
+		if err := s.world.FakeEnv.Set(kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *state) exists(neg bool, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: exists path...")
+	}
+	for _, arg := range args {
+		ok, err := afero.Exists(s.world.FakeFs, arg)
+		if err != nil {
+			return err
+		}
+		if ok && neg {
+			return fmt.Errorf("%s unexpectedly exists", arg)
+		}
+		if !ok && !neg {
+			return fmt.Errorf("%s does not exist", arg)
+		}
+	}
+	return nil
+}
+
+func (s *state) mkdir(args []string) error {
+	for _, arg := range args {
+		if err := s.world.FakeFs.MkdirAll(arg, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *state) rm(args []string) error {
+	for _, arg := range args {
+		if err := s.world.FakeFs.RemoveAll(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
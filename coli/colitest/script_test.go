@@ -0,0 +1,76 @@
+package colitest_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/taskie/ose"
+	"github.com/taskie/ose/coli"
+	"github.com/taskie/ose/coli/colitest"
+)
+
+func newGreetCommand(cl *coli.Coli, name string, path []string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:  name,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := cl.Viper()
+			o := ose.NewOpenerWithContext(cmd.Context())
+			input := "-"
+			if len(args) == 1 {
+				input = args[0]
+			}
+			r, err := o.Open(input)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			bs, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			w, err := o.Create(v.GetString("output"))
+			if err != nil {
+				return err
+			}
+			defer w.Close()
+			for _, line := range strings.Fields(string(bs)) {
+				fmt.Fprintf(w, "%s, %s\n", v.GetString("greeting"), line)
+			}
+			if v.GetBool("verbose") {
+				if s := v.GetString("loudness"); s != "" {
+					cmd.PrintErr(s + "\n")
+				}
+				cmd.PrintErr("done\n")
+			}
+			return nil
+		},
+	}
+	cl.Prepare(cmd)
+	cmd.Flags().StringP("greeting", "g", "hello", "greeting")
+	cmd.Flags().StringP("output", "o", "-", "output file")
+	cl.BindFlags(cmd.Flags(), []string{"greeting", "output"})
+	return cmd
+}
+
+func TestScripts(t *testing.T) {
+	colitest.Run(t, colitest.Params{
+		Commands: map[string]coli.ColiCommandProducer{
+			"greet": newGreetCommand,
+			"plain": colitest.Prepared(func(cl *coli.Coli, name string, path []string) *cobra.Command {
+				return &cobra.Command{
+					Use: name,
+					Run: func(cmd *cobra.Command, args []string) {
+						cmd.Printf("verbose: %v\n", cl.Viper().GetBool("verbose"))
+					},
+				}
+			}),
+		},
+		Setup: func(w *ose.FakeWorld) error {
+			return w.FakeEnv.Set("HOME", "/home/user")
+		},
+	})
+}
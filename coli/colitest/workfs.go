@@ -0,0 +1,47 @@
+package colitest
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// workFs resolves relative paths against dir, which works as the working directory in the fake filesystem.
+type workFs struct {
+	fs  afero.Fs
+	dir string
+}
+
+func (w *workFs) abs(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(w.dir, name)
+}
+
+func (w *workFs) Create(name string) (afero.File, error) { return w.fs.Create(w.abs(name)) }
+func (w *workFs) Mkdir(name string, perm os.FileMode) error {
+	return w.fs.Mkdir(w.abs(name), perm)
+}
+func (w *workFs) MkdirAll(path string, perm os.FileMode) error {
+	return w.fs.MkdirAll(w.abs(path), perm)
+}
+func (w *workFs) Open(name string) (afero.File, error) { return w.fs.Open(w.abs(name)) }
+func (w *workFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return w.fs.OpenFile(w.abs(name), flag, perm)
+}
+func (w *workFs) Remove(name string) error    { return w.fs.Remove(w.abs(name)) }
+func (w *workFs) RemoveAll(path string) error { return w.fs.RemoveAll(w.abs(path)) }
+func (w *workFs) Rename(oldname, newname string) error {
+	return w.fs.Rename(w.abs(oldname), w.abs(newname))
+}
+func (w *workFs) Stat(name string) (os.FileInfo, error) { return w.fs.Stat(w.abs(name)) }
+func (w *workFs) Name() string                          { return "workFs" }
+func (w *workFs) Chmod(name string, mode os.FileMode) error {
+	return w.fs.Chmod(w.abs(name), mode)
+}
+func (w *workFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return w.fs.Chtimes(w.abs(name), atime, mtime)
+}
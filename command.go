@@ -0,0 +1,236 @@
+package ose
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// Cmd describes an external command, like exec.Cmd.
+// Nil Env, Stdin, Stdout and Stderr are taken from the World of the Commander.
+type Cmd struct {
+	Path      string
+	Args      []string
+	Dir       string
+	Env       []string
+	Stdin     io.Reader
+	Stdout    io.Writer
+	Stderr    io.Writer
+	commander Commander
+}
+
+// Command creates a Cmd which is run by the Commander c.
+func Command(c Commander, name string, arg ...string) *Cmd {
+	return &Cmd{
+		Path:      name,
+		Args:      append([]string{name}, arg...),
+		commander: c,
+	}
+}
+
+func (cmd *Cmd) Run() error {
+	return cmd.commander.Run(cmd)
+}
+
+func (cmd *Cmd) Output() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	cmd.Stdout = buf
+	err := cmd.Run()
+	return buf.Bytes(), err
+}
+
+func (cmd *Cmd) CombinedOutput() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	cmd.Stdout = buf
+	cmd.Stderr = buf
+	err := cmd.Run()
+	return buf.Bytes(), err
+}
+
+// ExitError reports that a command exited with a non-zero status.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return fmt.Sprintf("exit status %d", e.Code) }
+func (e *ExitError) Unwrap() error { return e.Err }
+
+type Commander interface {
+	Run(cmd *Cmd) error
+}
+
+type realCommander struct {
+	io  IO
+	env Env
+}
+
+// NewCommander creates a Commander backed by os/exec.
+// Commands are resolved on the real file system against PATH of env, and inherit env and io unless the Cmd overrides them.
+func NewCommander(io IO, env Env) Commander {
+	return &realCommander{io: io, env: env}
+}
+
+func (c *realCommander) Run(cmd *Cmd) error {
+	name, err := NewEnvPath(afero.NewOsFs(), c.env).LookCommand(cmd.Path)
+	if err != nil {
+		return &exec.Error{Name: cmd.Path, Err: exec.ErrNotFound}
+	}
+	ec := exec.Command(name)
+	ec.Args = cmd.Args
+	ec.Dir = cmd.Dir
+	ec.Env = cmd.Env
+	if ec.Env == nil {
+		ec.Env = c.env.Environ()
+	}
+	ec.Stdin = cmd.Stdin
+	if ec.Stdin == nil {
+		ec.Stdin = c.io.In()
+	}
+	ec.Stdout = cmd.Stdout
+	if ec.Stdout == nil {
+		ec.Stdout = c.io.Out()
+	}
+	ec.Stderr = cmd.Stderr
+	if ec.Stderr == nil {
+		ec.Stderr = c.io.Err()
+	}
+	err = ec.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Code: exitErr.ExitCode(), Err: exitErr}
+	}
+	return err
+}
+
+// FakeCommandResult is a canned result of a FakeCommander.
+// If Func is set, it is called instead of writing Stdout and Stderr.
+type FakeCommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Err      error
+	Func     func(cmd *Cmd) error
+}
+
+// FakeCommandCall records an invocation of a FakeCommander.
+type FakeCommandCall struct {
+	Args  []string
+	Dir   string
+	Env   []string
+	Stdin []byte
+}
+
+type fakeCommandRule struct {
+	pattern []string
+	result  *FakeCommandResult
+}
+
+// FakeCommander is a scriptable Commander which records invocations.
+type FakeCommander struct {
+	io    IO
+	rules []fakeCommandRule
+	calls []*FakeCommandCall
+	mutex sync.Mutex
+}
+
+func NewFakeCommander(io IO) *FakeCommander {
+	return &FakeCommander{io: io}
+}
+
+// Register adds a canned result for argv matching pattern.
+// Each element of pattern is matched with path.Match, and a trailing "..." matches any remaining arguments.
+// Rules are tried in the order they were registered.
+func (c *FakeCommander) Register(pattern []string, result *FakeCommandResult) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rules = append(c.rules, fakeCommandRule{pattern: pattern, result: result})
+}
+
+// Calls returns the recorded invocations.
+func (c *FakeCommander) Calls() []*FakeCommandCall {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]*FakeCommandCall{}, c.calls...)
+}
+
+func matchArgs(pattern []string, args []string) bool {
+	for i, p := range pattern {
+		if p == "..." && i == len(pattern)-1 {
+			return true
+		}
+		if i >= len(args) {
+			return false
+		}
+		if ok, err := path.Match(p, args[i]); err != nil || !ok {
+			return false
+		}
+	}
+	return len(pattern) == len(args)
+}
+
+func (c *FakeCommander) lookup(args []string) *FakeCommandResult {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, rule := range c.rules {
+		if matchArgs(rule.pattern, args) {
+			return rule.result
+		}
+	}
+	return nil
+}
+
+// Run records cmd and applies the first matching rule.
+// Unlike the real Commander, Stdin of the World is not consumed when cmd.Stdin is nil.
+func (c *FakeCommander) Run(cmd *Cmd) error {
+	call := &FakeCommandCall{
+		Args: append([]string{}, cmd.Args...),
+		Dir:  cmd.Dir,
+		Env:  append([]string{}, cmd.Env...),
+	}
+	if cmd.Stdin != nil {
+		bs, err := ioutil.ReadAll(cmd.Stdin)
+		if err != nil {
+			return err
+		}
+		call.Stdin = bs
+		cmd.Stdin = bytes.NewReader(bs)
+	}
+	c.mutex.Lock()
+	c.calls = append(c.calls, call)
+	c.mutex.Unlock()
+
+	result := c.lookup(cmd.Args)
+	if result == nil {
+		return &exec.Error{Name: cmd.Path, Err: exec.ErrNotFound}
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = c.io.Out()
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = c.io.Err()
+	}
+	if result.Func != nil {
+		return result.Func(cmd)
+	}
+	if _, err := io.WriteString(cmd.Stdout, result.Stdout); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(cmd.Stderr, result.Stderr); err != nil {
+		return err
+	}
+	if result.Err != nil {
+		return result.Err
+	}
+	if result.ExitCode != 0 {
+		return &ExitError{Code: result.ExitCode}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package ose_test
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+func TestFakeCommander(t *testing.T) {
+	w := ose.NewFakeWorld()
+	c := w.FakeCommander
+	c.Register([]string{"git", "rev-parse", "..."}, &ose.FakeCommandResult{Stdout: "abc\n"})
+	c.Register([]string{"git", "*"}, &ose.FakeCommandResult{Stderr: "fatal\n", ExitCode: 128})
+
+	bs, err := ose.Command(w.Commander(), "git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "abc\n" {
+		t.Fatalf("invalid output: %s", bs)
+	}
+
+	err = ose.Command(w.Commander(), "git", "status").Run()
+	var exitErr *ose.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 128 {
+		t.Fatalf("invalid error: %v", err)
+	}
+	if w.FakeIO.ErrBuf.String() != "fatal\n" {
+		t.Fatalf("invalid stderr: %s", w.FakeIO.ErrBuf.String())
+	}
+
+	cmd := ose.Command(w.Commander(), "git", "push", "origin", "master")
+	err = cmd.Run()
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Fatalf("invalid error: %v", err)
+	}
+
+	calls := c.Calls()
+	if len(calls) != 3 {
+		t.Fatalf("invalid calls: %v", calls)
+	}
+	if strings.Join(calls[2].Args, " ") != "git push origin master" {
+		t.Fatalf("invalid args: %v", calls[2].Args)
+	}
+}
+
+func TestFakeCommanderFunc(t *testing.T) {
+	w := ose.NewFakeWorld()
+	w.FakeCommander.Register([]string{"cat"}, &ose.FakeCommandResult{Func: func(cmd *ose.Cmd) error {
+		_, err := cmd.Stdout.Write(w.FakeCommander.Calls()[0].Stdin)
+		return err
+	}})
+	cmd := ose.Command(w.Commander(), "cat")
+	cmd.Stdin = strings.NewReader("hello")
+	bs, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "hello" {
+		t.Fatalf("invalid output: %s", bs)
+	}
+}
+
+func TestCommander(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available")
+	}
+	env := ose.NewMapEnv()
+	_ = env.Set("PATH", "/usr/local/bin:/usr/bin:/bin")
+	_ = env.Set("OSE_TEST", "hello")
+	w := ose.NewWorldContainer(afero.NewOsFs(), ose.NewBufIOContainer(), env, nil)
+	bs, err := ose.Command(w.Commander(), "sh", "-c", "echo $OSE_TEST").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "hello\n" {
+		t.Fatalf("invalid output: %s", bs)
+	}
+	err = ose.Command(w.Commander(), "sh", "-c", "exit 3").Run()
+	var exitErr *ose.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("invalid error: %v", err)
+	}
+	err = ose.Command(w.Commander(), "ose-no-such-command").Run()
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Fatalf("invalid error: %v", err)
+	}
+	// commands are looked up on the real file system even if the World has a fake one
+	w = ose.NewWorldContainer(afero.NewMemMapFs(), ose.NewBufIOContainer(), env, nil)
+	err = ose.Command(w.Commander(), "sh", "-c", "exit 0").Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,211 @@
+package ose
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// CompressionCodec is a compression format which an Opener can read and write transparently.
+type CompressionCodec struct {
+	Name string
+	// Extensions are file name suffixes including dots, like ".gz".
+	Extensions []string
+	// Magic is the bytes with which compressed data start.
+	Magic []byte
+	// Detect additionally checks the header starting with Magic if it is not nil.
+	// The header is at least 16 bytes long unless the data is shorter.
+	Detect func(header []byte) bool
+	// NewReader decompresses r.
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+	// NewWriter compresses into w, and must not close w. It is nil if the codec is read-only.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+var (
+	compressionCodecsMutex sync.RWMutex
+	compressionCodecs      []*CompressionCodec
+)
+
+// RegisterCompressionCodec adds c to the registry, replacing a codec with the same name.
+func RegisterCompressionCodec(c *CompressionCodec) {
+	compressionCodecsMutex.Lock()
+	defer compressionCodecsMutex.Unlock()
+	for i, old := range compressionCodecs {
+		if old.Name == c.Name {
+			compressionCodecs[i] = c
+			return
+		}
+	}
+	compressionCodecs = append(compressionCodecs, c)
+}
+
+// CompressionCodecs returns all registered codecs.
+func CompressionCodecs() []*CompressionCodec {
+	compressionCodecsMutex.RLock()
+	defer compressionCodecsMutex.RUnlock()
+	return append([]*CompressionCodec{}, compressionCodecs...)
+}
+
+// LookupCompressionCodec returns the codec registered as name.
+func LookupCompressionCodec(name string) (*CompressionCodec, bool) {
+	for _, c := range CompressionCodecs() {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// CompressionCodecForFileName returns the codec whose extension the file name has, or nil.
+func CompressionCodecForFileName(name string) *CompressionCodec {
+	for _, c := range CompressionCodecs() {
+		for _, ext := range c.Extensions {
+			if strings.HasSuffix(name, ext) {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// DetectCompressionCodec returns the codec whose magic bytes header starts with, or nil.
+func DetectCompressionCodec(header []byte) *CompressionCodec {
+	for _, c := range CompressionCodecs() {
+		if len(c.Magic) != 0 && bytes.HasPrefix(header, c.Magic) && (c.Detect == nil || c.Detect(header)) {
+			return c
+		}
+	}
+	return nil
+}
+
+func headerLength() int {
+	n := 16
+	for _, c := range CompressionCodecs() {
+		if len(c.Magic) > n {
+			n = len(c.Magic)
+		}
+	}
+	return n
+}
+
+// NewDecompressingReader decompresses rc if it starts with the magic bytes of a registered codec.
+// Closing the returned reader also closes rc, and rc is closed if an error is returned.
+func NewDecompressingReader(rc io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(rc)
+	header, err := br.Peek(headerLength())
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		rc.Close()
+		return nil, err
+	}
+	c := DetectCompressionCodec(header)
+	if c == nil {
+		return NewReadCloser(br, func(_ io.Reader) error {
+			return rc.Close()
+		}), nil
+	}
+	return newCodecReader(c, br, rc)
+}
+
+func newCodecReader(c *CompressionCodec, r io.Reader, rc io.ReadCloser) (io.ReadCloser, error) {
+	dr, err := c.NewReader(r)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("%s: %w", c.Name, err)
+	}
+	return ExtendReadCloser(dr, func(dr io.ReadCloser) error {
+		err := dr.Close()
+		err2 := rc.Close()
+		if err != nil {
+			return err
+		}
+		return err2
+	}), nil
+}
+
+// NewCompressingWriter compresses into wc by c. Closing the returned writer flushes the compressor and closes wc.
+// wc is closed if an error is returned.
+func NewCompressingWriter(c *CompressionCodec, wc io.WriteCloser) (io.WriteCloser, error) {
+	if c.NewWriter == nil {
+		wc.Close()
+		return nil, fmt.Errorf("%s: compression is not supported", c.Name)
+	}
+	cw, err := c.NewWriter(wc)
+	if err != nil {
+		wc.Close()
+		return nil, fmt.Errorf("%s: %w", c.Name, err)
+	}
+	return NewWriteCloser(cw, func(_ io.Writer) error {
+		err := cw.Close()
+		err2 := wc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.Name, err)
+		}
+		return err2
+	}), nil
+}
+
+func init() {
+	RegisterCompressionCodec(&CompressionCodec{
+		Name:       "gzip",
+		Extensions: []string{".gz", ".tgz"},
+		Magic:      []byte{0x1f, 0x8b},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+	})
+	RegisterCompressionCodec(&CompressionCodec{
+		Name:       "bzip2",
+		Extensions: []string{".bz2", ".tbz2"},
+		Magic:      []byte("BZh"),
+		Detect: func(header []byte) bool {
+			// the block size
+			return len(header) > 3 && '1' <= header[3] && header[3] <= '9'
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bzip2.NewReader(r)), nil
+		},
+	})
+	RegisterCompressionCodec(&CompressionCodec{
+		Name:       "xz",
+		Extensions: []string{".xz", ".txz"},
+		Magic:      []byte{0xfd, '7', 'z', 'X', 'Z', 0x00},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			xr, err := xz.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(xr), nil
+		},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return xz.NewWriter(w)
+		},
+	})
+	RegisterCompressionCodec(&CompressionCodec{
+		Name:       "zstd",
+		Extensions: []string{".zst", ".tzst"},
+		Magic:      []byte{0x28, 0xb5, 0x2f, 0xfd},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			d, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		},
+	})
+}
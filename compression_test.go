@@ -0,0 +1,212 @@
+package ose_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+// bzip2 -c <<< 'hello, bzip2'
+var bzip2Hello = []byte{66, 90, 104, 57, 49, 65, 89, 38, 83, 89, 177, 35, 222, 67, 0, 0, 3, 89, 128, 0, 16, 64, 4, 16, 0, 18, 100, 192, 16, 32, 0, 49, 3, 64, 208, 32, 1, 166, 145, 3, 171, 108, 130, 132, 248, 187, 146, 41, 194, 132, 133, 137, 30, 242, 24}
+
+func readAll(t *testing.T, o *ose.Opener, name string) string {
+	t.Helper()
+	rc, err := o.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	bs, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bs)
+}
+
+func TestOpenerAutoCompression(t *testing.T) {
+	for _, name := range []string{"foo.gz", "foo.xz", "foo.zst", "foo.txt"} {
+		w := ose.NewFakeWorld()
+		o := ose.NewOpenerInWorld(w)
+		o.AutoCompression = true
+		wc, err := o.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(wc, "hello, "+name)
+		err = wc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := afero.ReadFile(w.FakeFs, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := ose.DetectCompressionCodec(raw)
+		if ext := ose.CompressionCodecForFileName(name); c != ext {
+			t.Fatalf("invalid codec: %s: %v", name, c)
+		}
+		if s := readAll(t, o, name); s != "hello, "+name {
+			t.Fatalf("invalid content: %s: %q", name, s)
+		}
+		o.AutoCompression = false
+		if s := readAll(t, o, name); s != string(raw) {
+			t.Fatalf("must not decompress: %s", name)
+		}
+	}
+}
+
+func TestOpenerAutoCompressionStdin(t *testing.T) {
+	w := ose.NewFakeWorld()
+	w.FakeIO.InBuf.Write(bzip2Hello)
+	o := ose.NewOpenerInWorld(w)
+	o.AutoCompression = true
+	if s := readAll(t, o, "-"); s != "hello, bzip2\n" {
+		t.Fatalf("invalid content: %q", s)
+	}
+
+	w.FakeIO.InBuf.WriteString("BZ")
+	if s := readAll(t, o, "-"); s != "BZ" {
+		t.Fatalf("invalid content: %q", s)
+	}
+
+	_, err := o.Create("foo.bz2")
+	if err == nil {
+		t.Fatal("bzip2 must be read-only")
+	}
+	if ok, _ := afero.Exists(w.FakeFs, "foo.bz2"); ok {
+		t.Fatal("must not be created")
+	}
+}
+
+func TestOpenerCompression(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.Compression = "gzip"
+	wc, err := o.Create("-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "foo")
+	wc.Close()
+	if c := ose.DetectCompressionCodec(w.FakeIO.OutBuf.Bytes()); c == nil || c.Name != "gzip" {
+		t.Fatalf("invalid codec: %v", c)
+	}
+	w.FakeIO.InBuf.Write(w.FakeIO.OutBuf.Bytes())
+	if s := readAll(t, o, "-"); s != "foo" {
+		t.Fatalf("invalid content: %q", s)
+	}
+
+	o.Compression = "unknown"
+	_, err = o.Open("-")
+	if err == nil {
+		t.Fatal("must fail")
+	}
+}
+
+func TestOpenerCompressionViaTempFile(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.AutoCompression = true
+	ok, err := o.CreateTempFile("", "opener", "foo.zst", func(f io.WriteCloser) (bool, error) {
+		_, err := io.WriteString(f, "foo")
+		return true, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("must be ok")
+	}
+	if s := readAll(t, o, "foo.zst"); s != "foo" {
+		t.Fatalf("invalid content: %q", s)
+	}
+}
+
+type rot13Writer struct{ w io.Writer }
+
+func rot13(bs []byte) []byte {
+	out := make([]byte, len(bs))
+	for i, b := range bs {
+		switch {
+		case 'a' <= b && b <= 'z':
+			b = 'a' + (b-'a'+13)%26
+		case 'A' <= b && b <= 'Z':
+			b = 'A' + (b-'A'+13)%26
+		}
+		out[i] = b
+	}
+	return out
+}
+
+func (w *rot13Writer) Write(p []byte) (int, error) { return w.w.Write(rot13(p)) }
+func (w *rot13Writer) Close() error                { return nil }
+
+func TestRegisterCompressionCodec(t *testing.T) {
+	magic := []byte("ROT13\n")
+	ose.RegisterCompressionCodec(&ose.CompressionCodec{
+		Name:       "rot13",
+		Extensions: []string{".rot13"},
+		Magic:      magic,
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			bs, err := ioutil.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(bytes.NewReader(rot13(bs[len(magic):]))), nil
+		},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			_, err := w.Write(magic)
+			return &rot13Writer{w: w}, err
+		},
+	})
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.AutoCompression = true
+	wc, err := o.Create("foo.rot13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "Hello")
+	wc.Close()
+	raw, _ := afero.ReadFile(w.FakeFs, "foo.rot13")
+	if string(raw) != "ROT13\nUryyb" {
+		t.Fatalf("invalid content: %q", raw)
+	}
+	if s := readAll(t, o, "foo.rot13"); s != "Hello" {
+		t.Fatalf("invalid content: %q", s)
+	}
+}
+
+func TestDetectCompressionCodecBzip2(t *testing.T) {
+	if c := ose.DetectCompressionCodec(bzip2Hello); c == nil || c.Name != "bzip2" {
+		t.Fatalf("invalid codec: %v", c)
+	}
+	if c := ose.DetectCompressionCodec([]byte("BZhello")); c != nil {
+		t.Fatalf("invalid codec: %v", c.Name)
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestNewDecompressingReaderError(t *testing.T) {
+	rc := &closeRecorder{Reader: bytes.NewReader([]byte{0x1f, 0x8b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})}
+	_, err := ose.NewDecompressingReader(rc)
+	if err == nil {
+		t.Fatal("must fail")
+	}
+	if !rc.closed {
+		t.Fatal("must be closed")
+	}
+}
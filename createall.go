@@ -0,0 +1,251 @@
+package ose
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// CreateAllOptions configures Opener.CreateAllWithOptions.
+type CreateAllOptions struct {
+	// KeepGoing keeps writing to the other destinations when one fails. The failures are returned by Close.
+	KeepGoing bool
+	// Atomic writes each file to a temporary file in the same directory, and Close moves all of them to
+	// the destinations only if no destination failed. Stdout is written directly.
+	// Close checks and syncs every temporary file before moving the first one, but moving itself can still fail
+	// in the middle. Then Close returns a *CommitError with the destinations already replaced.
+	Atomic bool
+}
+
+// CommitError is returned by Close of CreateAll in Atomic mode when moving a temporary file fails.
+// The destinations in Committed were replaced, and the others were left untouched.
+type CommitError struct {
+	Committed []string
+	Err       error
+}
+
+func (e *CommitError) Error() string {
+	if len(e.Committed) == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v (committed: %s)", e.Err, strings.Join(e.Committed, ", "))
+}
+
+func (e *CommitError) Unwrap() error { return e.Err }
+
+// CreateAll writes the same stream to several destinations like tee. See CreateAllWithOptions.
+func (o *Opener) CreateAll(names []string) (io.WriteCloser, error) {
+	return o.CreateAllWithOptions(names, nil)
+}
+
+// CreateAllWithOptions returns a writer which writes to every destination created as Create does.
+// Stdout ("-") is written only once, and no names mean stdout.
+// Errors of destinations are *os.PathError with the names, and Close returns them as a MultiError.
+func (o *Opener) CreateAllWithOptions(names []string, opts *CreateAllOptions) (io.WriteCloser, error) {
+	if opts == nil {
+		opts = &CreateAllOptions{}
+	}
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	mw := &multiFileWriter{o: o, opts: opts}
+	stdout := false
+	for _, name := range names {
+		if o.shouldFallback(name) {
+			if stdout {
+				continue
+			}
+			stdout = true
+		}
+		sink, err := mw.create(name)
+		if err != nil {
+			err = pathError("create", name, err)
+			if !opts.KeepGoing {
+				mw.abort()
+				return nil, err
+			}
+			mw.errs = append(mw.errs, err)
+			continue
+		}
+		mw.sinks = append(mw.sinks, sink)
+	}
+	return mw, nil
+}
+
+type fileSink struct {
+	name string
+	wc   io.WriteCloser
+	temp afero.File
+	// hash computes the digest of temp for the sidecar if not nil.
+	hash *HashWriteCloser
+	dead bool
+}
+
+type multiFileWriter struct {
+	o      *Opener
+	opts   *CreateAllOptions
+	sinks  []*fileSink
+	errs   MultiError
+	closed bool
+}
+
+func (mw *multiFileWriter) create(name string) (*fileSink, error) {
+	if !mw.opts.Atomic || mw.o.shouldFallback(name) {
+		wc, err := mw.o.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		return &fileSink{name: name, wc: wc}, nil
+	}
+	name, err := mw.o.resolveFilePath("create temp file", name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := mw.o.codecForCreate(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := mw.o.checkClobber(name); err != nil {
+		return nil, err
+	}
+	f, err := afero.TempFile(mw.o.fs, filepath.Dir(name), "."+filepath.Base(name)+".")
+	if err != nil {
+		return nil, err
+	}
+	var w io.Writer = f
+	var hwc *HashWriteCloser
+	if mw.o.ChecksumSidecar {
+		hwc, _ = NewHashWriteCloser(f, "sha256")
+		w = hwc
+	}
+	if err := mw.o.appendExisting(f, w, name); err != nil {
+		_ = f.Close()
+		_ = mw.o.fs.Remove(f.Name())
+		return nil, err
+	}
+	wc, err := mw.o.wrapTempFile(w, c)
+	if err != nil {
+		_ = f.Close()
+		_ = mw.o.fs.Remove(f.Name())
+		return nil, err
+	}
+	return &fileSink{name: name, wc: wc, temp: f, hash: hwc}, nil
+}
+
+func (mw *multiFileWriter) fail(sink *fileSink, op string, err error) {
+	sink.dead = true
+	mw.errs = append(mw.errs, pathError(op, sink.name, err))
+}
+
+func (mw *multiFileWriter) Write(p []byte) (int, error) {
+	if !mw.opts.KeepGoing && len(mw.errs) != 0 {
+		return 0, mw.errs
+	}
+	alive := false
+	for _, sink := range mw.sinks {
+		if sink.dead {
+			continue
+		}
+		n, err := sink.wc.Write(p)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			mw.fail(sink, "write", err)
+			if !mw.opts.KeepGoing {
+				return n, mw.errs
+			}
+			continue
+		}
+		alive = true
+	}
+	if !alive && len(mw.errs) != 0 {
+		return 0, mw.errs
+	}
+	return len(p), nil
+}
+
+// abort closes all destinations and removes the temporary files.
+func (mw *multiFileWriter) abort() {
+	for _, sink := range mw.sinks {
+		_ = sink.wc.Close()
+		if sink.temp != nil {
+			_ = sink.temp.Close()
+			_ = mw.o.fs.Remove(sink.temp.Name())
+		}
+	}
+}
+
+func (mw *multiFileWriter) Close() error {
+	if mw.closed {
+		return nil
+	}
+	mw.closed = true
+	for _, sink := range mw.sinks {
+		if err := sink.wc.Close(); err != nil && !sink.dead {
+			mw.fail(sink, "close", err)
+		}
+	}
+	mw.commit()
+	if len(mw.errs) != 0 {
+		return mw.errs
+	}
+	return nil
+}
+
+// commit moves the temporary files to the destinations after all of them are checked and synced.
+func (mw *multiFileWriter) commit() {
+	s := mw.o.TempScope()
+	temps := make([]*fileSink, 0, len(mw.sinks))
+	for _, sink := range mw.sinks {
+		if sink.temp != nil {
+			temps = append(temps, sink)
+		}
+	}
+	discard := func(sinks []*fileSink) {
+		for _, sink := range sinks {
+			_ = sink.temp.Close()
+			_ = mw.o.fs.Remove(sink.temp.Name())
+		}
+	}
+	if len(mw.errs) == 0 {
+		for _, sink := range temps {
+			if err := mw.o.beforeCommit(sink.name); err != nil {
+				mw.errs = append(mw.errs, pathError("commit", sink.name, err))
+			}
+		}
+	}
+	if len(mw.errs) != 0 {
+		discard(temps)
+		return
+	}
+	for i, sink := range temps {
+		if err := s.closeTemp(sink.temp); err != nil {
+			mw.errs = append(mw.errs, pathError("commit", sink.name, err))
+			discard(temps[i+1:])
+			for _, closed := range temps[:i] {
+				_ = mw.o.fs.Remove(closed.temp.Name())
+			}
+			return
+		}
+	}
+	committed := make([]string, 0, len(temps))
+	for i, sink := range temps {
+		if err := s.moveTemp(sink.temp.Name(), sink.name); err != nil {
+			for _, rest := range temps[i+1:] {
+				_ = mw.o.fs.Remove(rest.temp.Name())
+			}
+			mw.errs = append(mw.errs, &CommitError{Committed: committed, Err: pathError("commit", sink.name, err)})
+			return
+		}
+		committed = append(committed, sink.name)
+		if sink.hash != nil {
+			if err := mw.o.writeSidecar(sink.name, sink.hash.SumHex("sha256")); err != nil {
+				mw.errs = append(mw.errs, pathError("write sidecar", sink.name, err))
+			}
+		}
+	}
+}
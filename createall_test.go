@@ -0,0 +1,217 @@
+package ose_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("broken pipe") }
+
+func TestOpenerCreateAll(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	wc, err := o.CreateAll([]string{"a.txt", "-", "b.txt", "-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "foo")
+	io.WriteString(wc, "bar")
+	err = wc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "b.txt"} {
+		bs, _ := afero.ReadFile(w.FakeFs, name)
+		if string(bs) != "foobar" {
+			t.Fatalf("invalid content: %s: %q", name, bs)
+		}
+	}
+	if s := w.FakeIO.OutString(); s != "foobar" {
+		t.Fatalf("invalid output: %q", s)
+	}
+}
+
+func TestOpenerCreateAllError(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.Unbuffered = true
+	o.FallbackWriter = failingWriter{}
+	o.ResolveURLs = true
+	_, err := o.CreateAll([]string{"a.txt", "data:,b"})
+	var perr *os.PathError
+	if !errors.As(err, &perr) || perr.Path != "data:,b" {
+		t.Fatalf("invalid error: %v", err)
+	}
+
+	wc, err := o.CreateAll([]string{"a.txt", "-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.WriteString(wc, "foo")
+	if err == nil {
+		t.Fatal("must fail")
+	}
+	_, err = io.WriteString(wc, "bar")
+	if err == nil {
+		t.Fatal("must abort")
+	}
+	err = wc.Close()
+	merr, ok := err.(ose.MultiError)
+	if !ok || len(merr) != 1 || !errors.As(merr[0], &perr) || perr.Path != "-" {
+		t.Fatalf("invalid error: %v", err)
+	}
+	bs, _ := afero.ReadFile(w.FakeFs, "a.txt")
+	if string(bs) != "foo" {
+		t.Fatalf("invalid content: %q", bs)
+	}
+
+	wc, err = o.CreateAllWithOptions([]string{"c.txt", "-", "data:,d"}, &ose.CreateAllOptions{KeepGoing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "foo")
+	_, err = io.WriteString(wc, "bar")
+	if err != nil {
+		t.Fatalf("must keep going: %v", err)
+	}
+	err = wc.Close()
+	if merr, ok := err.(ose.MultiError); !ok || len(merr) != 2 {
+		t.Fatalf("invalid error: %v", err)
+	}
+	bs, _ = afero.ReadFile(w.FakeFs, "c.txt")
+	if string(bs) != "foobar" {
+		t.Fatalf("invalid content: %q", bs)
+	}
+}
+
+func TestOpenerCreateAllAtomic(t *testing.T) {
+	w := ose.NewFakeWorld()
+	w.FakeFs.MkdirAll("/dir", 0755)
+	o := ose.NewOpenerInWorld(w)
+	o.AutoCompression = true
+	opts := &ose.CreateAllOptions{Atomic: true}
+	wc, err := o.CreateAllWithOptions([]string{"/dir/a.txt", "/dir/b.txt.gz"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "foo")
+	if ose.Exists(w.FakeFs, "/dir/a.txt") {
+		t.Fatal("must not be committed yet")
+	}
+	err = wc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := readAll(t, o, "/dir/a.txt"); s != "foo" {
+		t.Fatalf("invalid content: %q", s)
+	}
+	if s := readAll(t, o, "/dir/b.txt.gz"); s != "foo" {
+		t.Fatalf("invalid content: %q", s)
+	}
+
+	o.Unbuffered = true
+	o.FallbackWriter = failingWriter{}
+	opts.KeepGoing = true
+	wc, err = o.CreateAllWithOptions([]string{"/dir/c.txt", "-"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "foo")
+	err = wc.Close()
+	if err == nil {
+		t.Fatal("must fail")
+	}
+	if ose.Exists(w.FakeFs, "/dir/c.txt") {
+		t.Fatal("must not be committed")
+	}
+	entries, _ := afero.ReadDir(w.FakeFs, "/dir")
+	if len(entries) != 2 {
+		t.Fatalf("temporary files must be removed: %v", entries)
+	}
+}
+
+func TestOpenerCreateAllAtomicSidecar(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.ChecksumSidecar = true
+	wc, err := o.CreateAllWithOptions([]string{"a.txt", "b.txt"}, &ose.CreateAllOptions{Atomic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "foo")
+	err = wc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.ChecksumSidecar = false
+	for _, name := range []string{"a.txt", "b.txt"} {
+		rc, err := o.OpenVerified(name, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(ioutil.Discard, rc)
+		err = rc.Close()
+		if err != nil {
+			t.Fatalf("invalid sidecar: %s: %v", name, err)
+		}
+	}
+}
+
+func TestOpenerCreateAllAtomicPartialCommit(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "b.txt", []byte("old"), 0644)
+	o := ose.NewOpener(&moveFailingFs{Fs: w.FakeFs, target: "b.txt"}, w.IO())
+	wc, err := o.CreateAllWithOptions([]string{"a.txt", "b.txt", "c.txt"}, &ose.CreateAllOptions{Atomic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "new")
+	err = wc.Close()
+	var cerr *ose.CommitError
+	if !errors.As(err, &cerr) || len(cerr.Committed) != 1 || cerr.Committed[0] != "a.txt" {
+		t.Fatalf("invalid error: %v", err)
+	}
+	var perr *os.PathError
+	if !errors.As(err, &perr) || perr.Path != "b.txt" {
+		t.Fatalf("invalid error: %v", err)
+	}
+	assertFile(t, w.FakeFs, "a.txt", "new")
+	assertFile(t, w.FakeFs, "b.txt", "old")
+	if ok, _ := afero.Exists(w.FakeFs, "c.txt"); ok {
+		t.Fatal("must not commit c.txt")
+	}
+	fis, _ := afero.ReadDir(w.FakeFs, ".")
+	if len(fis) != 2 {
+		t.Fatalf("temporary files must be removed: %v", fis)
+	}
+}
+
+func TestOpenerCreateAllAtomicCheckAll(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.NoClobber = true
+	wc, err := o.CreateAllWithOptions([]string{"a.txt", "b.txt"}, &ose.CreateAllOptions{Atomic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "new")
+	// another process creates b.txt meanwhile
+	afero.WriteFile(w.FakeFs, "b.txt", []byte("other"), 0644)
+	err = wc.Close()
+	var cerr *ose.ClobberError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("invalid error: %v", err)
+	}
+	if ok, _ := afero.Exists(w.FakeFs, "a.txt"); ok {
+		t.Fatal("must not commit a.txt")
+	}
+	assertFile(t, w.FakeFs, "b.txt", "other")
+}
@@ -0,0 +1,632 @@
+package ose
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v2"
+)
+
+// RecordReader reads records one by one.
+type RecordReader interface {
+	// Read decodes the next record into v. It returns io.EOF after the last record.
+	Read(v interface{}) error
+}
+
+// RecordWriter writes records one by one.
+type RecordWriter interface {
+	Write(v interface{}) error
+	Flush() error
+}
+
+// DataFormat is a format of structured data which an Opener can decode and encode.
+type DataFormat struct {
+	Name string
+	// Extensions are file name suffixes including dots, like ".json".
+	Extensions []string
+	// Decode decodes the whole data of r into v.
+	Decode func(r io.Reader, v interface{}) error
+	// Encode encodes v into w.
+	Encode func(w io.Writer, v interface{}) error
+	// NewRecordReader and NewRecordWriter are nil unless the format is a sequence of records.
+	// A RecordWriter may fix the structure by the first record. The one of CSV makes the header of its keys,
+	// and fails on a record with another key, while Encode of CSV makes the header of the keys of all records.
+	NewRecordReader func(r io.Reader) RecordReader
+	NewRecordWriter func(w io.Writer) RecordWriter
+}
+
+var (
+	dataFormatsMutex sync.RWMutex
+	dataFormats      []*DataFormat
+)
+
+// RegisterDataFormat adds f to the registry, replacing a format with the same name.
+func RegisterDataFormat(f *DataFormat) {
+	dataFormatsMutex.Lock()
+	defer dataFormatsMutex.Unlock()
+	for i, old := range dataFormats {
+		if old.Name == f.Name {
+			dataFormats[i] = f
+			return
+		}
+	}
+	dataFormats = append(dataFormats, f)
+}
+
+// DataFormats returns all registered formats.
+func DataFormats() []*DataFormat {
+	dataFormatsMutex.RLock()
+	defer dataFormatsMutex.RUnlock()
+	return append([]*DataFormat{}, dataFormats...)
+}
+
+// LookupDataFormat returns the format registered as name, or the format with the extension name like "yml".
+func LookupDataFormat(name string) (*DataFormat, bool) {
+	name = strings.ToLower(name)
+	for _, f := range DataFormats() {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	for _, f := range DataFormats() {
+		for _, ext := range f.Extensions {
+			if ext == "."+name {
+				return f, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// DataFormatForFileName returns the format whose extension the file name has, or nil.
+// The extension of a compression codec is ignored, so "foo.json.gz" is JSON.
+func DataFormatForFileName(name string) *DataFormat {
+	if c := CompressionCodecForFileName(name); c != nil {
+		for _, ext := range c.Extensions {
+			name = strings.TrimSuffix(name, ext)
+		}
+	}
+	name = strings.ToLower(name)
+	for _, f := range DataFormats() {
+		for _, ext := range f.Extensions {
+			if strings.HasSuffix(name, ext) {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// assignValue stores x into v. Values of the other types than interface{} and map[string]interface{} are converted via JSON.
+func assignValue(v interface{}, x interface{}) error {
+	switch p := v.(type) {
+	case *interface{}:
+		*p = x
+		return nil
+	case *map[string]interface{}:
+		if m, ok := x.(map[string]interface{}); ok {
+			*p = m
+			return nil
+		}
+	}
+	bs, err := json.Marshal(x)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, v)
+}
+
+// normalizeYAML converts map[interface{}]interface{} decoded by YAML into map[string]interface{}.
+func normalizeYAML(x interface{}) interface{} {
+	switch x := x.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, v := range x {
+			m[fmt.Sprint(k)] = normalizeYAML(v)
+		}
+		return m
+	case map[string]interface{}:
+		for k, v := range x {
+			x[k] = normalizeYAML(v)
+		}
+		return x
+	case []interface{}:
+		for i, v := range x {
+			x[i] = normalizeYAML(v)
+		}
+		return x
+	}
+	return x
+}
+
+func decodeYAML(r io.Reader, v interface{}) error {
+	switch v.(type) {
+	case *interface{}, *map[string]interface{}:
+		var x interface{}
+		err := yaml.NewDecoder(r).Decode(&x)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		return assignValue(v, normalizeYAML(x))
+	}
+	err := yaml.NewDecoder(r).Decode(v)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+func encodeYAML(w io.Writer, v interface{}) error {
+	e := yaml.NewEncoder(w)
+	err := e.Encode(v)
+	if err != nil {
+		return err
+	}
+	return e.Close()
+}
+
+func isStructPointer(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	return t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct
+}
+
+// integralFloatsToInts converts float64 values decoded from JSON like 8080 into int64, which TOML distinguishes.
+func integralFloatsToInts(x interface{}) interface{} {
+	switch x := x.(type) {
+	case float64:
+		if x == math.Trunc(x) && math.Abs(x) < 1<<53 {
+			return int64(x)
+		}
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, v := range x {
+			m[k] = integralFloatsToInts(v)
+		}
+		return m
+	case []interface{}:
+		xs := make([]interface{}, len(x))
+		for i, v := range x {
+			xs[i] = integralFloatsToInts(v)
+		}
+		return xs
+	}
+	return x
+}
+
+func decodeTOML(r io.Reader, v interface{}) error {
+	tree, err := toml.LoadReader(r)
+	if err != nil {
+		return err
+	}
+	if isStructPointer(v) {
+		return tree.Unmarshal(v)
+	}
+	return assignValue(v, tree.ToMap())
+}
+
+func encodeTOML(w io.Writer, v interface{}) error {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Ptr {
+			rv = rv.Elem()
+		}
+		if rv.Kind() == reflect.Struct {
+			return toml.NewEncoder(w).Encode(rv.Interface())
+		}
+		err := assignValue(&m, v)
+		if err != nil {
+			return fmt.Errorf("toml: %w", err)
+		}
+	}
+	tree, err := toml.TreeFromMap(integralFloatsToInts(m).(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+	_, err = tree.WriteTo(w)
+	return err
+}
+
+// decodeRecords decodes all records into v as a slice.
+func decodeRecords(rr RecordReader, v interface{}) error {
+	xs := make([]interface{}, 0)
+	for {
+		var x interface{}
+		err := rr.Read(&x)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		xs = append(xs, x)
+	}
+	return assignValue(v, xs)
+}
+
+// encodeRecords encodes each element of v as a record.
+func encodeRecords(rw RecordWriter, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fmt.Errorf("records must be a slice: %T", v)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		err := rw.Write(rv.Index(i).Interface())
+		if err != nil {
+			return err
+		}
+	}
+	return rw.Flush()
+}
+
+type ndjsonRecordReader struct {
+	dec *json.Decoder
+}
+
+func (rr *ndjsonRecordReader) Read(v interface{}) error {
+	return rr.dec.Decode(v)
+}
+
+type ndjsonRecordWriter struct {
+	enc *json.Encoder
+}
+
+func (rw *ndjsonRecordWriter) Write(v interface{}) error {
+	return rw.enc.Encode(v)
+}
+
+func (rw *ndjsonRecordWriter) Flush() error { return nil }
+
+// csvRecordReader treats the first row as the header, and reads the other rows as maps from the header.
+type csvRecordReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+func (rr *csvRecordReader) Read(v interface{}) error {
+	if rr.header == nil {
+		header, err := rr.r.Read()
+		if err != nil {
+			return err
+		}
+		rr.header = header
+	}
+	row, err := rr.r.Read()
+	if err != nil {
+		return err
+	}
+	if len(row) != len(rr.header) {
+		return fmt.Errorf("csv: wrong number of fields: %d, header has %d", len(row), len(rr.header))
+	}
+	switch p := v.(type) {
+	case *[]string:
+		*p = row
+		return nil
+	case *map[string]string:
+		m := make(map[string]string, len(row))
+		for i, k := range rr.header {
+			m[k] = row[i]
+		}
+		*p = m
+		return nil
+	}
+	m := make(map[string]interface{}, len(row))
+	for i, k := range rr.header {
+		m[k] = row[i]
+	}
+	return assignValue(v, m)
+}
+
+// csvRecordWriter writes []string as it is, and the other records as maps under the header made of the sorted keys of the first one
+// unless the header is given.
+type csvRecordWriter struct {
+	w      *csv.Writer
+	header []string
+}
+
+func csvField(x interface{}) (string, error) {
+	switch x := x.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return x, nil
+	}
+	bs, err := json.Marshal(x)
+	return string(bs), err
+}
+
+// csvMap converts a record other than []string into a map.
+func csvMap(v interface{}) (map[string]interface{}, error) {
+	switch x := v.(type) {
+	case map[string]string:
+		m := make(map[string]interface{}, len(x))
+		for k, s := range x {
+			m[k] = s
+		}
+		return m, nil
+	case map[string]interface{}:
+		return x, nil
+	}
+	var m map[string]interface{}
+	err := assignValue(&m, v)
+	if err != nil {
+		return nil, fmt.Errorf("csv: %w", err)
+	}
+	return m, nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (rw *csvRecordWriter) writeHeader(header []string) error {
+	rw.header = header
+	return rw.w.Write(header)
+}
+
+func (rw *csvRecordWriter) Write(v interface{}) error {
+	if row, ok := v.([]string); ok {
+		return rw.w.Write(row)
+	}
+	m, err := csvMap(v)
+	if err != nil {
+		return err
+	}
+	if rw.header == nil {
+		err := rw.writeHeader(sortedKeys(m))
+		if err != nil {
+			return err
+		}
+	}
+	row := make([]string, len(rw.header))
+	for i, k := range rw.header {
+		s, err := csvField(m[k])
+		if err != nil {
+			return err
+		}
+		row[i] = s
+	}
+	for k := range m {
+		if i := sort.SearchStrings(rw.header, k); i == len(rw.header) || rw.header[i] != k {
+			return fmt.Errorf("csv: field not in header: %s", k)
+		}
+	}
+	return rw.w.Write(row)
+}
+
+func (rw *csvRecordWriter) Flush() error {
+	rw.w.Flush()
+	return rw.w.Error()
+}
+
+// encodeCSV encodes each element of v as a record under the header made of the sorted keys of all of them.
+func encodeCSV(w io.Writer, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fmt.Errorf("records must be a slice: %T", v)
+	}
+	keys := make(map[string]interface{})
+	for i := 0; i < rv.Len(); i++ {
+		x := rv.Index(i).Interface()
+		if _, ok := x.([]string); ok {
+			continue
+		}
+		m, err := csvMap(x)
+		if err != nil {
+			return err
+		}
+		for k := range m {
+			keys[k] = nil
+		}
+	}
+	rw := &csvRecordWriter{w: csv.NewWriter(w)}
+	if len(keys) != 0 {
+		err := rw.writeHeader(sortedKeys(keys))
+		if err != nil {
+			return err
+		}
+	}
+	return encodeRecords(rw, v)
+}
+
+func newNDJSONRecordReader(r io.Reader) RecordReader {
+	return &ndjsonRecordReader{dec: json.NewDecoder(r)}
+}
+
+func newNDJSONRecordWriter(w io.Writer) RecordWriter {
+	return &ndjsonRecordWriter{enc: json.NewEncoder(w)}
+}
+
+func newCSVRecordReader(r io.Reader) RecordReader {
+	return &csvRecordReader{r: csv.NewReader(r)}
+}
+
+func newCSVRecordWriter(w io.Writer) RecordWriter {
+	return &csvRecordWriter{w: csv.NewWriter(w)}
+}
+
+func init() {
+	RegisterDataFormat(&DataFormat{
+		Name:       "json",
+		Extensions: []string{".json"},
+		Decode: func(r io.Reader, v interface{}) error {
+			return json.NewDecoder(r).Decode(v)
+		},
+		Encode: func(w io.Writer, v interface{}) error {
+			e := json.NewEncoder(w)
+			e.SetIndent("", "  ")
+			return e.Encode(v)
+		},
+	})
+	RegisterDataFormat(&DataFormat{
+		Name:       "yaml",
+		Extensions: []string{".yaml", ".yml"},
+		Decode:     decodeYAML,
+		Encode:     encodeYAML,
+	})
+	RegisterDataFormat(&DataFormat{
+		Name:       "toml",
+		Extensions: []string{".toml"},
+		Decode:     decodeTOML,
+		Encode:     encodeTOML,
+	})
+	RegisterDataFormat(&DataFormat{
+		Name:       "csv",
+		Extensions: []string{".csv"},
+		Decode: func(r io.Reader, v interface{}) error {
+			if p, ok := v.(*[][]string); ok {
+				rows, err := csv.NewReader(r).ReadAll()
+				*p = rows
+				return err
+			}
+			return decodeRecords(newCSVRecordReader(r), v)
+		},
+		Encode:          encodeCSV,
+		NewRecordReader: newCSVRecordReader,
+		NewRecordWriter: newCSVRecordWriter,
+	})
+	RegisterDataFormat(&DataFormat{
+		Name:       "ndjson",
+		Extensions: []string{".ndjson", ".jsonl"},
+		Decode: func(r io.Reader, v interface{}) error {
+			return decodeRecords(newNDJSONRecordReader(r), v)
+		},
+		Encode: func(w io.Writer, v interface{}) error {
+			return encodeRecords(newNDJSONRecordWriter(w), v)
+		},
+		NewRecordReader: newNDJSONRecordReader,
+		NewRecordWriter: newNDJSONRecordWriter,
+	})
+}
+
+// FileDataFormat returns the format of name by its extension, or DataFormat of the Opener.
+func (o *Opener) FileDataFormat(name string) (*DataFormat, error) {
+	if !o.shouldFallback(name) {
+		if f := DataFormatForFileName(name); f != nil {
+			return f, nil
+		}
+	}
+	if o.DataFormat == "" {
+		return nil, fmt.Errorf("%s: unknown data format", name)
+	}
+	f, ok := LookupDataFormat(o.DataFormat)
+	if !ok {
+		return nil, fmt.Errorf("unknown data format: %s", o.DataFormat)
+	}
+	return f, nil
+}
+
+// DecodeFile decodes the file into v in the format inferred from the extension, or DataFormat.
+func (o *Opener) DecodeFile(name string, v interface{}) error {
+	f, err := o.FileDataFormat(name)
+	if err != nil {
+		return err
+	}
+	rc, err := o.Open(name)
+	if err != nil {
+		return err
+	}
+	err = f.Decode(rc, v)
+	err2 := rc.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return err2
+}
+
+// EncodeFile encodes v into the file in the format inferred from the extension, or DataFormat.
+// v is encoded before the file is created, so that the file is left as it is if encoding fails.
+func (o *Opener) EncodeFile(name string, v interface{}) error {
+	f, err := o.FileDataFormat(name)
+	if err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	err = f.Encode(buf, v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	wc, err := o.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(wc)
+	err2 := wc.Close()
+	if err != nil {
+		return err
+	}
+	return err2
+}
+
+// RecordReadCloser reads records from a file opened by Opener.OpenRecords.
+type RecordReadCloser struct {
+	RecordReader
+	io.Closer
+}
+
+// RecordWriteCloser writes records to a file created by Opener.CreateRecords. Close flushes the records.
+type RecordWriteCloser struct {
+	RecordWriter
+	closer io.Closer
+}
+
+func (rwc *RecordWriteCloser) Close() error {
+	err := rwc.Flush()
+	err2 := rwc.closer.Close()
+	if err != nil {
+		return err
+	}
+	return err2
+}
+
+// OpenRecords opens the file to read records one by one, in a format like NDJSON and CSV.
+func (o *Opener) OpenRecords(name string) (*RecordReadCloser, error) {
+	f, err := o.FileDataFormat(name)
+	if err != nil {
+		return nil, err
+	}
+	if f.NewRecordReader == nil {
+		return nil, fmt.Errorf("%s: records are not supported", f.Name)
+	}
+	rc, err := o.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordReadCloser{RecordReader: f.NewRecordReader(rc), Closer: rc}, nil
+}
+
+// CreateRecords creates the file to write records one by one, in a format like NDJSON and CSV.
+func (o *Opener) CreateRecords(name string) (*RecordWriteCloser, error) {
+	f, err := o.FileDataFormat(name)
+	if err != nil {
+		return nil, err
+	}
+	if f.NewRecordWriter == nil {
+		return nil, fmt.Errorf("%s: records are not supported", f.Name)
+	}
+	wc, err := o.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordWriteCloser{RecordWriter: f.NewRecordWriter(wc), closer: wc}, nil
+}
@@ -0,0 +1,234 @@
+package ose_test
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+type dataConfig struct {
+	Name  string   `json:"name" yaml:"name" toml:"name"`
+	Port  int      `json:"port" yaml:"port" toml:"port"`
+	Tags  []string `json:"tags" yaml:"tags" toml:"tags"`
+	Debug bool     `json:"debug" yaml:"debug" toml:"debug"`
+}
+
+func TestDataFormatForFileName(t *testing.T) {
+	cases := map[string]string{
+		"foo.json":    "json",
+		"foo.YML":     "yaml",
+		"foo.toml":    "toml",
+		"foo.csv.gz":  "csv",
+		"foo.jsonl":   "ndjson",
+		"foo.ndjson":  "ndjson",
+		"foo.json.xz": "json",
+	}
+	for name, expected := range cases {
+		f := ose.DataFormatForFileName(name)
+		if f == nil || f.Name != expected {
+			t.Fatalf("invalid format: %s: %v", name, f)
+		}
+	}
+	if f := ose.DataFormatForFileName("foo.txt"); f != nil {
+		t.Fatalf("must be unknown: %v", f)
+	}
+	if f, ok := ose.LookupDataFormat("yml"); !ok || f.Name != "yaml" {
+		t.Fatalf("invalid format: %v", f)
+	}
+}
+
+func TestOpenerDataRoundTrip(t *testing.T) {
+	expected := dataConfig{Name: "foo", Port: 8080, Tags: []string{"a", "b"}, Debug: true}
+	for _, name := range []string{"foo.json", "foo.yaml", "foo.toml", "foo.json.gz"} {
+		w := ose.NewFakeWorld()
+		o := ose.NewOpenerInWorld(w)
+		o.AutoCompression = true
+		err := o.EncodeFile(name, &expected)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var actual dataConfig
+		err = o.DecodeFile(name, &actual)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("invalid value: %s: %v", name, actual)
+		}
+	}
+}
+
+func TestOpenerDataConvert(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "foo.yaml", []byte("name: foo\nserver:\n  port: 8080\n"), 0644)
+	o := ose.NewOpenerInWorld(w)
+	var v interface{}
+	err := o.DecodeFile("foo.yaml", &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo.json", "foo.toml"} {
+		err = o.EncodeFile(name, v)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var m map[string]interface{}
+		err = o.DecodeFile(name, &m)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		server, ok := m["server"].(map[string]interface{})
+		if !ok || m["name"] != "foo" {
+			t.Fatalf("invalid value: %s: %v", name, m)
+		}
+		if port := reflect.ValueOf(server["port"]); port.Convert(reflect.TypeOf(0)).Int() != 8080 {
+			t.Fatalf("invalid port: %s: %v", name, server["port"])
+		}
+	}
+}
+
+func TestOpenerDataStdin(t *testing.T) {
+	w := ose.NewFakeWorld()
+	w.FakeIO.WriteInString(`{"name": "foo"}`)
+	o := ose.NewOpenerInWorld(w)
+	var v dataConfig
+	err := o.DecodeFile("-", &v)
+	if err == nil {
+		t.Fatal("must fail without DataFormat")
+	}
+	o.DataFormat = "json"
+	err = o.DecodeFile("-", &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "foo" {
+		t.Fatalf("invalid value: %v", v)
+	}
+	o.DataFormat = "yaml"
+	err = o.EncodeFile("-", &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := w.FakeIO.OutString(); s != "name: foo\nport: 0\ntags: []\ndebug: false\n" {
+		t.Fatalf("invalid output: %q", s)
+	}
+}
+
+func TestOpenerRecords(t *testing.T) {
+	expected := []map[string]string{
+		{"id": "1", "name": "foo"},
+		{"id": "2", "name": "bar, baz"},
+	}
+	for _, name := range []string{"foo.csv", "foo.ndjson"} {
+		w := ose.NewFakeWorld()
+		o := ose.NewOpenerInWorld(w)
+		rw, err := o.CreateRecords(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range expected {
+			err = rw.Write(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = rw.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr, err := o.OpenRecords(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := make([]map[string]string, 0)
+		for {
+			var r map[string]string
+			err := rr.Read(&r)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual = append(actual, r)
+		}
+		rr.Close()
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("invalid records: %s: %v", name, actual)
+		}
+		var all []map[string]string
+		err = o.DecodeFile(name, &all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(all, expected) {
+			t.Fatalf("invalid records: %s: %v", name, all)
+		}
+	}
+}
+
+func TestOpenerEncodeFileError(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "foo.json", []byte("{}\n"), 0644)
+	o := ose.NewOpenerInWorld(w)
+	err := o.EncodeFile("foo.json", map[string]interface{}{"foo": make(chan int)})
+	if err == nil {
+		t.Fatal("must fail")
+	}
+	bs, _ := afero.ReadFile(w.FakeFs, "foo.json")
+	if s := string(bs); s != "{}\n" {
+		t.Fatalf("invalid content: %q", s)
+	}
+}
+
+func TestOpenerRecordsCSV(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	err := o.EncodeFile("foo.csv", []interface{}{
+		map[string]interface{}{"b": 1.5, "a": "x"},
+		map[string]interface{}{"a": "y", "b": nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, _ := afero.ReadFile(w.FakeFs, "foo.csv")
+	if s := string(bs); s != "a,b\nx,1.5\ny,\n" {
+		t.Fatalf("invalid content: %q", s)
+	}
+	err = o.EncodeFile("bar.csv", []map[string]string{{"a": "x"}, {"b": "y"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, _ = afero.ReadFile(w.FakeFs, "bar.csv")
+	if s := string(bs); s != "a,b\nx,\n,y\n" {
+		t.Fatalf("invalid content: %q", s)
+	}
+	rw, err := o.CreateRecords("baz.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rw.Write(map[string]string{"a": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rw.Write(map[string]string{"b": "y"})
+	if err == nil {
+		t.Fatal("must fail with an unknown field")
+	}
+	rw.Close()
+	var rows [][]string
+	err = o.DecodeFile("foo.csv", &rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rows, [][]string{{"a", "b"}, {"x", "1.5"}, {"y", ""}}) {
+		t.Fatalf("invalid rows: %v", rows)
+	}
+	_, err = o.OpenRecords("foo.json")
+	if err == nil {
+		t.Fatal("JSON must not support records")
+	}
+}
@@ -0,0 +1,144 @@
+package ose
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+func NewRealEnv() Env { return RealEnv{} }
+
+// OverlayEnv is an Env which stacks a writable MapEnv layer over a parent Env.
+// Writes never reach the parent, and unset keys are hidden by tombstones.
+type OverlayEnv struct {
+	parent  Env
+	layer   *MapEnv
+	unset   map[string]bool
+	cleared bool
+	mutex   sync.RWMutex
+}
+
+func NewOverlayEnv(parent Env) *OverlayEnv {
+	return &OverlayEnv{
+		parent: parent,
+		layer:  NewMapEnv(),
+		unset:  make(map[string]bool),
+	}
+}
+
+func (e *OverlayEnv) Parent() Env    { return e.parent }
+func (e *OverlayEnv) Layer() *MapEnv { return e.layer }
+
+func (e *OverlayEnv) Get(key string) string {
+	v, _ := e.Lookup(key)
+	return v
+}
+
+func (e *OverlayEnv) Lookup(key string) (string, bool) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if v, ok := e.layer.Lookup(key); ok {
+		return v, true
+	}
+	if e.cleared || e.unset[key] {
+		return "", false
+	}
+	return e.parent.Lookup(key)
+}
+
+func (e *OverlayEnv) Set(key string, value string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	delete(e.unset, key)
+	return e.layer.Set(key, value)
+}
+
+func (e *OverlayEnv) Unset(key string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.unset[key] = true
+	return e.layer.Unset(key)
+}
+
+// Clear hides all variables of the parent.
+func (e *OverlayEnv) Clear() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.layer.Clear()
+	e.unset = make(map[string]bool)
+	e.cleared = true
+}
+
+// Reset discards all changes and makes the parent visible again.
+func (e *OverlayEnv) Reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.layer.Clear()
+	e.unset = make(map[string]bool)
+	e.cleared = false
+}
+
+// Environ returns "key=value" pairs sorted by key.
+func (e *OverlayEnv) Environ() []string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	m := make(map[string]string)
+	if !e.cleared {
+		for _, kv := range e.parent.Environ() {
+			i := strings.Index(kv, "=")
+			if i <= 0 {
+				continue
+			}
+			if !e.unset[kv[:i]] {
+				m[kv[:i]] = kv[i+1:]
+			}
+		}
+	}
+	for k, v := range e.layer.Snapshot() {
+		m[k] = v
+	}
+	return environ(m)
+}
+
+func (e *OverlayEnv) Expand(s string) string {
+	return os.Expand(s, e.Get)
+}
+
+// OverrideEnv sets vars on env and returns a function which restores the previous state.
+// The returned function can be deferred directly.
+func OverrideEnv(env Env, vars map[string]string) (func(), error) {
+	type previous struct {
+		value string
+		ok    bool
+	}
+	prevs := make(map[string]previous)
+	restore := func() {
+		for k, prev := range prevs {
+			if prev.ok {
+				_ = env.Set(k, prev.value)
+			} else {
+				_ = env.Unset(k)
+			}
+		}
+	}
+	for k, v := range vars {
+		value, ok := env.Lookup(k)
+		prevs[k] = previous{value: value, ok: ok}
+		if err := env.Set(k, v); err != nil {
+			restore()
+			return func() {}, err
+		}
+	}
+	return restore, nil
+}
+
+// WithEnv calls f while vars are set on env, and restores the previous state afterwards.
+func WithEnv(env Env, vars map[string]string, f func()) error {
+	restore, err := OverrideEnv(env, vars)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	f()
+	return nil
+}
@@ -0,0 +1,90 @@
+package ose_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+func TestOverlayEnv(t *testing.T) {
+	parent := ose.NewMapEnv()
+	_ = parent.Set("FOO", "foo")
+	_ = parent.Set("BAR", "bar")
+	env := ose.NewOverlayEnv(parent)
+	_ = env.Set("FOO", "FOO")
+	_ = env.Set("BAZ", "baz")
+	_ = env.Unset("BAR")
+	if s := strings.Join(env.Environ(), " "); s != "BAZ=baz FOO=FOO" {
+		t.Fatalf("invalid environ: %s", s)
+	}
+	if _, ok := env.Lookup("BAR"); ok {
+		t.Fatal("must be unset")
+	}
+	if s := strings.Join(parent.Environ(), " "); s != "BAR=bar FOO=foo" {
+		t.Fatalf("parent must not be changed: %s", s)
+	}
+	_ = env.Set("BAR", "BAR")
+	if v := env.Get("BAR"); v != "BAR" {
+		t.Fatalf("invalid value: %s", v)
+	}
+	env.Clear()
+	if len(env.Environ()) != 0 {
+		t.Fatalf("must be cleared: %v", env.Environ())
+	}
+	env.Reset()
+	if s := strings.Join(env.Environ(), " "); s != "BAR=bar FOO=foo" {
+		t.Fatalf("invalid environ: %s", s)
+	}
+}
+
+func TestOverlayEnvInWorldContainer(t *testing.T) {
+	const key = "OSE_TEST_OVERLAY"
+	env := ose.NewOverlayEnv(ose.NewRealEnv())
+	w := ose.NewWorldContainer(afero.NewMemMapFs(), ose.NewBufIOContainer(), env, ose.NewFakeClock(epoch, 0))
+	_ = w.Env().Set(key, "overlay")
+	if v := w.Env().Get(key); v != "overlay" {
+		t.Fatalf("invalid value: %s", v)
+	}
+	if _, ok := os.LookupEnv(key); ok {
+		t.Fatal("must not leak to the real environment")
+	}
+	if v := w.Env().Get("PATH"); v != os.Getenv("PATH") {
+		t.Fatalf("invalid value: %s", v)
+	}
+}
+
+func TestWithEnv(t *testing.T) {
+	env := ose.NewMapEnv()
+	_ = env.Set("FOO", "foo")
+	err := ose.WithEnv(env, map[string]string{"FOO": "FOO", "BAR": "bar"}, func() {
+		if s := strings.Join(env.Environ(), " "); s != "BAR=bar FOO=FOO" {
+			t.Fatalf("invalid environ: %s", s)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(env.Environ(), " "); s != "FOO=foo" {
+		t.Fatalf("must be restored: %s", s)
+	}
+}
+
+func TestOverrideEnv(t *testing.T) {
+	env := ose.NewMapEnv()
+	t.Run("override", func(t *testing.T) {
+		restore, err := ose.OverrideEnv(env, map[string]string{"FOO": "foo"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer restore()
+		if v := env.Get("FOO"); v != "foo" {
+			t.Fatalf("invalid value: %s", v)
+		}
+	})
+	if _, ok := env.Lookup("FOO"); ok {
+		t.Fatal("must be restored")
+	}
+}
@@ -19,6 +19,17 @@ func NewEnvPath(fs afero.Fs, env Env) *EnvPath {
 	return &EnvPath{fs: fs, env: env}
 }
 
+// HOME
+
+func (p *EnvPath) homeDir() (string, error) {
+	for _, key := range []string{"HOME", "USERPROFILE"} {
+		if v := p.env.Get(key); v != "" {
+			return v, nil
+		}
+	}
+	return homedir.Dir()
+}
+
 // GOPATH, PATH
 
 func (p *EnvPath) GetGoPath() (string, error) {
@@ -26,7 +37,7 @@ func (p *EnvPath) GetGoPath() (string, error) {
 	if len(vs) > 0 {
 		return vs[0], nil
 	}
-	v, err := homedir.Dir()
+	v, err := p.homeDir()
 	if err != nil {
 		return "", err
 	}
@@ -58,7 +69,7 @@ func (p *EnvPath) GetXdgConfigHome() (string, error) {
 	if v, ok := p.env.Lookup(XdgConfigHomeKey); ok {
 		return v, nil
 	}
-	v, err := homedir.Dir()
+	v, err := p.homeDir()
 	if err != nil {
 		return "", err
 	}
@@ -69,7 +80,7 @@ func (p *EnvPath) GetXdgCacheHome() (string, error) {
 	if v, ok := p.env.Lookup(XdgCacheHomeKey); ok {
 		return v, nil
 	}
-	v, err := homedir.Dir()
+	v, err := p.homeDir()
 	if err != nil {
 		return "", err
 	}
@@ -77,10 +88,10 @@ func (p *EnvPath) GetXdgCacheHome() (string, error) {
 }
 
 func (p *EnvPath) GetXdgDataHome() (string, error) {
-	if v, ok := p.env.Lookup(XdgCacheHomeKey); ok {
+	if v, ok := p.env.Lookup(XdgDataHomeKey); ok {
 		return v, nil
 	}
-	v, err := homedir.Dir()
+	v, err := p.homeDir()
 	if err != nil {
 		return "", err
 	}
@@ -108,7 +119,7 @@ func (p *EnvPath) LookPathWithPredicate(dirs []string, names []string, pred func
 	for _, dir := range dirs {
 		for _, name := range names {
 			fpath := filepath.Join(dir, name)
-			fi, err := os.Stat(fpath)
+			fi, err := p.fs.Stat(fpath)
 			if err != nil {
 				continue
 			}
@@ -124,6 +135,16 @@ func (p *EnvPath) LookPath(dirs []string, names ...string) (string, error) {
 	return p.LookPathWithPredicate(dirs, names, func(_ string, _ os.FileInfo) bool { return true })
 }
 
+// LookCommand resolves name to an executable file against PATH, like exec.LookPath.
+func (p *EnvPath) LookCommand(name string) (string, error) {
+	if strings.ContainsAny(name, "/"+string(filepath.Separator)) {
+		return name, nil
+	}
+	return p.LookPathWithPredicate(p.GetPath(), []string{name}, func(_ string, fi os.FileInfo) bool {
+		return fi.Mode().IsRegular() && fi.Mode().Perm()&0111 != 0
+	})
+}
+
 func (p *EnvPath) LookPathAll(dirs []string, names ...string) []string {
 	results := make([]string, 0)
 	p.LookPathWithPredicate(dirs, names, func(fpath string, _ os.FileInfo) bool {
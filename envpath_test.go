@@ -23,3 +23,44 @@ func TestXdg(t *testing.T) {
 	_ = p.GetXdgDataDirs()
 	_ = p.GetXdgConfigDirs()
 }
+
+func TestLookCommand(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	env := ose.NewMapEnv()
+	_ = env.Set("PATH", "/usr/bin:/bin")
+	_ = afero.WriteFile(fs, "/usr/bin/foo", []byte{}, 0644)
+	_ = afero.WriteFile(fs, "/bin/foo", []byte{}, 0755)
+	p := ose.NewEnvPath(fs, env)
+	fpath, err := p.LookCommand("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fpath != "/bin/foo" {
+		t.Fatalf("invalid path: %s", fpath)
+	}
+	_, err = p.LookCommand("bar")
+	if err == nil {
+		t.Fatal("must not be found")
+	}
+}
+
+func TestXdgWithHome(t *testing.T) {
+	env := ose.NewMapEnv()
+	_ = env.Set("HOME", "/home/ose")
+	p := ose.NewEnvPath(afero.NewMemMapFs(), env)
+	v, err := p.GetXdgConfigHome()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "/home/ose/.config" {
+		t.Fatalf("invalid path: %s", v)
+	}
+	_ = env.Set(ose.XdgDataHomeKey, "/data")
+	v, err = p.GetXdgDataHome()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "/data" {
+		t.Fatalf("invalid path: %s", v)
+	}
+}
@@ -0,0 +1,493 @@
+package ose
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/afero"
+	"golang.org/x/tools/txtar"
+)
+
+// FsSnapshotEntry is a file or a directory in an FsSnapshot.
+// Path is slash-separated and relative to the root of the snapshot.
+type FsSnapshotEntry struct {
+	Path    string
+	Mode    os.FileMode
+	ModTime time.Time
+	Content []byte
+}
+
+func (e *FsSnapshotEntry) IsDir() bool { return e.Mode.IsDir() }
+
+// FsSnapshot is a deterministic image of a subtree of an afero.Fs.
+type FsSnapshot struct {
+	Entries []*FsSnapshotEntry
+}
+
+type SnapshotOptions struct {
+	// ModTime records modification times, which are ignored otherwise.
+	ModTime bool
+	// Filter excludes entries for which it returns false. Excluded directories are skipped entirely.
+	Filter func(path string, fi os.FileInfo) bool
+}
+
+// SnapshotFs captures the subtree of fs under root.
+func SnapshotFs(fs afero.Fs, root string, opts *SnapshotOptions) (*FsSnapshot, error) {
+	if opts == nil {
+		opts = &SnapshotOptions{}
+	}
+	s := &FsSnapshot{Entries: make([]*FsSnapshotEntry, 0)}
+	err := afero.Walk(fs, root, func(fpath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, fpath)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if opts.Filter != nil && !opts.Filter(rel, fi) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		e := &FsSnapshotEntry{Path: rel, Mode: fi.Mode() & (os.ModeDir | os.ModePerm)}
+		if opts.ModTime {
+			e.ModTime = fi.ModTime().UTC()
+		}
+		switch fi.Mode() & os.ModeType {
+		case os.ModeDir:
+		case 0:
+			bs, err := afero.ReadFile(fs, fpath)
+			if err != nil {
+				return err
+			}
+			e.Content = bs
+		default:
+			return fmt.Errorf("unsupported file type: %s", fpath)
+		}
+		s.Entries = append(s.Entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.sort()
+	return s, nil
+}
+
+func (s *FsSnapshot) sort() {
+	sort.Slice(s.Entries, func(i, j int) bool { return s.Entries[i].Path < s.Entries[j].Path })
+}
+
+func (s *FsSnapshot) Lookup(path string) *FsSnapshotEntry {
+	for _, e := range s.Entries {
+		if e.Path == path {
+			return e
+		}
+	}
+	return nil
+}
+
+func (s *FsSnapshot) index() map[string]*FsSnapshotEntry {
+	m := make(map[string]*FsSnapshotEntry, len(s.Entries))
+	for _, e := range s.Entries {
+		if m[e.Path] == nil {
+			m[e.Path] = e
+		}
+	}
+	return m
+}
+
+// Restore creates the entries of s under root of fs.
+func (s *FsSnapshot) Restore(fs afero.Fs, root string) error {
+	for _, e := range s.Entries {
+		fpath := filepath.Join(root, filepath.FromSlash(e.Path))
+		if e.IsDir() {
+			if err := fs.MkdirAll(fpath, e.Mode.Perm()); err != nil {
+				return err
+			}
+		} else {
+			if err := fs.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+				return err
+			}
+			if err := afero.WriteFile(fs, fpath, e.Content, e.Mode.Perm()); err != nil {
+				return err
+			}
+		}
+		if err := fs.Chmod(fpath, e.Mode); err != nil {
+			return err
+		}
+		if !e.ModTime.IsZero() {
+			if err := fs.Chtimes(fpath, e.ModTime, e.ModTime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+const fsSnapshotHeader = "# ose fs snapshot"
+
+func isTxtarSafe(bs []byte) bool {
+	if !utf8.Valid(bs) {
+		return false
+	}
+	for _, b := range bs {
+		if (b < 0x20 && b != '\t' && b != '\n') || b == 0x7f {
+			return false
+		}
+	}
+	for _, line := range bytes.Split(bs, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("-- ")) && bytes.HasSuffix(line, []byte(" --")) {
+			return false
+		}
+	}
+	return true
+}
+
+// Marshal formats s as a txtar archive.
+// The comment lists "kind mode mtime flags path" for each entry, and file contents follow as archive files.
+func (s *FsSnapshot) Marshal() []byte {
+	return txtar.Format(s.archive())
+}
+
+func (s *FsSnapshot) archive() *txtar.Archive {
+	comment := new(bytes.Buffer)
+	fmt.Fprintln(comment, fsSnapshotHeader)
+	files := make([]txtar.File, 0)
+	for _, e := range s.Entries {
+		kind := "f"
+		if e.IsDir() {
+			kind = "d"
+		}
+		mtime := "-"
+		if !e.ModTime.IsZero() {
+			mtime = e.ModTime.UTC().Format(time.RFC3339Nano)
+		}
+		flags := "-"
+		data := e.Content
+		if !e.IsDir() {
+			switch {
+			case !isTxtarSafe(data):
+				flags = "base64"
+				data = []byte(wrapLines(base64.StdEncoding.EncodeToString(data), 76))
+			case len(data) != 0 && data[len(data)-1] != '\n':
+				flags = "noeol"
+				data = append(append([]byte{}, data...), '\n')
+			}
+			files = append(files, txtar.File{Name: e.Path, Data: data})
+		}
+		fmt.Fprintf(comment, "%s %04o %s %s %s\n", kind, uint32(e.Mode.Perm()), mtime, flags, e.Path)
+	}
+	return &txtar.Archive{Comment: comment.Bytes(), Files: files}
+}
+
+func wrapLines(s string, n int) string {
+	sb := new(strings.Builder)
+	for len(s) > n {
+		sb.WriteString(s[:n])
+		sb.WriteString("\n")
+		s = s[n:]
+	}
+	sb.WriteString(s)
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// UnmarshalFsSnapshot parses data formatted by FsSnapshot.Marshal.
+func UnmarshalFsSnapshot(data []byte) (*FsSnapshot, error) {
+	return parseFsSnapshot(txtar.Parse(data))
+}
+
+func parseFsSnapshot(a *txtar.Archive) (*FsSnapshot, error) {
+	contents := make(map[string][]byte)
+	for _, f := range a.Files {
+		contents[f.Name] = f.Data
+	}
+	s := &FsSnapshot{Entries: make([]*FsSnapshotEntry, 0)}
+	for _, line := range strings.Split(string(a.Comment), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 5)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("invalid snapshot entry: %s", line)
+		}
+		kind, modeStr, mtime, flags, path := fields[0], fields[1], fields[2], fields[3], fields[4]
+		perm, err := strconv.ParseUint(modeStr, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mode: %s", line)
+		}
+		e := &FsSnapshotEntry{Path: path, Mode: os.FileMode(perm)}
+		if mtime != "-" {
+			e.ModTime, err = time.Parse(time.RFC3339Nano, mtime)
+			if err != nil {
+				return nil, fmt.Errorf("invalid mtime: %s", line)
+			}
+		}
+		switch kind {
+		case "d":
+			e.Mode |= os.ModeDir
+		case "f":
+			data, ok := contents[path]
+			if !ok {
+				return nil, fmt.Errorf("content not found: %s", path)
+			}
+			switch flags {
+			case "-":
+				e.Content = data
+			case "noeol":
+				e.Content = bytes.TrimSuffix(data, []byte("\n"))
+			case "base64":
+				e.Content, err = base64.StdEncoding.DecodeString(strings.Replace(string(data), "\n", "", -1))
+				if err != nil {
+					return nil, fmt.Errorf("invalid base64 content: %s", path)
+				}
+			default:
+				return nil, fmt.Errorf("invalid flags: %s", line)
+			}
+		default:
+			return nil, fmt.Errorf("invalid kind: %s", line)
+		}
+		s.Entries = append(s.Entries, e)
+	}
+	s.sort()
+	return s, nil
+}
+
+func describeEntry(e *FsSnapshotEntry) string {
+	if e.IsDir() {
+		return fmt.Sprintf("d %04o %s/", uint32(e.Mode.Perm()), e.Path)
+	}
+	return fmt.Sprintf("f %04o %s (%d bytes)", uint32(e.Mode.Perm()), e.Path, len(e.Content))
+}
+
+// DiffFsSnapshots returns a human readable difference from a to b, or "" if they are equal.
+// Modification times are compared only if both entries have them.
+func DiffFsSnapshots(a, b *FsSnapshot) string {
+	sb := new(strings.Builder)
+	as, bs := a.index(), b.index()
+	sorted := make([]string, 0, len(as)+len(bs))
+	for p := range as {
+		sorted = append(sorted, p)
+	}
+	for p := range bs {
+		if as[p] == nil {
+			sorted = append(sorted, p)
+		}
+	}
+	sort.Strings(sorted)
+	for _, p := range sorted {
+		o, n := as[p], bs[p]
+		switch {
+		case o == nil:
+			fmt.Fprintf(sb, "+ %s\n", describeEntry(n))
+		case n == nil:
+			fmt.Fprintf(sb, "- %s\n", describeEntry(o))
+		case o.IsDir() != n.IsDir():
+			fmt.Fprintf(sb, "- %s\n+ %s\n", describeEntry(o), describeEntry(n))
+		default:
+			if o.Mode != n.Mode {
+				fmt.Fprintf(sb, "~ %s: mode %04o -> %04o\n", p, uint32(o.Mode.Perm()), uint32(n.Mode.Perm()))
+			}
+			if !o.ModTime.IsZero() && !n.ModTime.IsZero() && !o.ModTime.Equal(n.ModTime) {
+				fmt.Fprintf(sb, "~ %s: mtime %s -> %s\n", p, o.ModTime.Format(time.RFC3339Nano), n.ModTime.Format(time.RFC3339Nano))
+			}
+			if !bytes.Equal(o.Content, n.Content) {
+				if utf8.Valid(o.Content) && utf8.Valid(n.Content) {
+					fmt.Fprintf(sb, "~ %s: content\n", p)
+					sb.WriteString(DiffLines(string(o.Content), string(n.Content)))
+				} else {
+					fmt.Fprintf(sb, "~ %s: binary content (%d bytes -> %d bytes)\n", p, len(o.Content), len(n.Content))
+				}
+			}
+		}
+	}
+	return sb.String()
+}
+
+// DiffLines returns a line-based difference from s to t with two lines of context.
+// Each line is prefixed by "    -", "    +" or "     ", and omitted lines are shown as "    ...".
+func DiffLines(s, t string) string {
+	a, b := splitLines(s), splitLines(t)
+	d := &lineDiffer{a: a, b: b, ops: make([]diffOp, 0, len(a)+len(b))}
+	d.diff(0, len(a), 0, len(b))
+	ops := d.ops
+	const context = 2
+	visible := make([]bool, len(ops))
+	for k, o := range ops {
+		if o.mark == ' ' {
+			continue
+		}
+		for l := k - context; l <= k+context; l++ {
+			if 0 <= l && l < len(ops) {
+				visible[l] = true
+			}
+		}
+	}
+	sb := new(strings.Builder)
+	skipped := false
+	for k, o := range ops {
+		if !visible[k] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			sb.WriteString("    ...\n")
+			skipped = false
+		}
+		fmt.Fprintf(sb, "    %c%s\n", o.mark, o.line)
+	}
+	if skipped {
+		sb.WriteString("    ...\n")
+	}
+	return sb.String()
+}
+
+type diffOp struct {
+	mark byte
+	line string
+}
+
+// lineDiffer computes the shortest edit script of lines in linear space by the divide-and-conquer variant of the Myers algorithm.
+type lineDiffer struct {
+	a, b []string
+	ops  []diffOp
+}
+
+func (d *lineDiffer) diff(a0, a1, b0, b1 int) {
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		d.ops = append(d.ops, diffOp{' ', d.a[a0]})
+		a0++
+		b0++
+	}
+	suffix := 0
+	for a0 < a1 && b0 < b1 && d.a[a1-1] == d.b[b1-1] {
+		a1--
+		b1--
+		suffix++
+	}
+	switch {
+	case a0 == a1:
+		for ; b0 < b1; b0++ {
+			d.ops = append(d.ops, diffOp{'+', d.b[b0]})
+		}
+	case b0 == b1:
+		for ; a0 < a1; a0++ {
+			d.ops = append(d.ops, diffOp{'-', d.a[a0]})
+		}
+	default:
+		x, y := d.bisect(a0, a1, b0, b1)
+		d.diff(a0, x, b0, y)
+		d.diff(x, a1, y, b1)
+	}
+	for i := 0; i < suffix; i++ {
+		d.ops = append(d.ops, diffOp{' ', d.a[a1+i]})
+	}
+}
+
+// bisect finds the middle of a shortest edit path from (a0, b0) to (a1, b1) by searching from both ends.
+func (d *lineDiffer) bisect(a0, a1, b0, b1 int) (int, int) {
+	n, m := a1-a0, b1-b0
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	vf := make([]int, 2*maxD+2)
+	vb := make([]int, 2*maxD+2)
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[offset+1], vb[offset+1] = 0, 0
+	delta := n - m
+	front := delta%2 != 0
+	// trim diagonals which go out of the rectangle
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+	for e := 0; e < maxD; e++ {
+		for k := -e + fStart; k <= e-fEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -e || (k != e && vf[i-1] < vf[i+1]) {
+				x = vf[i+1]
+			} else {
+				x = vf[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[a0+x] == d.b[b0+y] {
+				x++
+				y++
+			}
+			vf[i] = x
+			if x > n {
+				fEnd += 2
+			} else if y > m {
+				fStart += 2
+			} else if front {
+				j := offset + delta - k
+				if 0 <= j && j < len(vb) && vb[j] != -1 && x >= n-vb[j] {
+					return a0 + x, b0 + y
+				}
+			}
+		}
+		for k := -e + bStart; k <= e-bEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -e || (k != e && vb[i-1] < vb[i+1]) {
+				x = vb[i+1]
+			} else {
+				x = vb[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[a1-1-x] == d.b[b1-1-y] {
+				x++
+				y++
+			}
+			vb[i] = x
+			if x > n {
+				bEnd += 2
+			} else if y > m {
+				bStart += 2
+			} else if !front {
+				j := offset + delta - k
+				if 0 <= j && j < len(vf) && vf[j] != -1 {
+					fx := vf[j]
+					fy := fx - (j - offset)
+					if fx >= n-x {
+						return a0 + fx, b0 + fy
+					}
+				}
+			}
+		}
+	}
+	// no common lines
+	return a1, b0
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for i, line := range lines {
+		if strings.HasSuffix(line, "\n") {
+			lines[i] = strings.TrimSuffix(line, "\n")
+		} else {
+			lines[i] = line + " (no newline at end)"
+		}
+	}
+	return lines
+}
@@ -0,0 +1,118 @@
+package ose_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+func newSnapshotFs() afero.Fs {
+	fs := afero.NewMemMapFs()
+	_ = fs.MkdirAll("/root/a/b", 0755)
+	_ = afero.WriteFile(fs, "/root/a/b/text.txt", []byte("foo\nbar\nbaz\n"), 0644)
+	_ = afero.WriteFile(fs, "/root/noeol.txt", []byte("foo"), 0600)
+	_ = afero.WriteFile(fs, "/root/binary", []byte{0xff, 0x00, 0x01}, 0644)
+	_ = afero.WriteFile(fs, "/root/marker.txt", []byte("-- fake --\n"), 0644)
+	return fs
+}
+
+func TestSnapshotFs(t *testing.T) {
+	fs := newSnapshotFs()
+	s, err := ose.SnapshotFs(fs, "/root", &ose.SnapshotOptions{ModTime: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make([]string, 0)
+	for _, e := range s.Entries {
+		paths = append(paths, e.Path)
+	}
+	expected := []string{"a", "a/b", "a/b/text.txt", "binary", "marker.txt", "noeol.txt"}
+	if len(paths) != len(expected) {
+		t.Fatalf("invalid paths: %v", paths)
+	}
+	for i := range paths {
+		if paths[i] != expected[i] {
+			t.Fatalf("invalid paths: %v", paths)
+		}
+	}
+
+	bs := s.Marshal()
+	s2, err := ose.UnmarshalFsSnapshot(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := ose.DiffFsSnapshots(s, s2); diff != "" {
+		t.Fatalf("must be equal:\n%s\n%s", diff, bs)
+	}
+	if !bytes.Equal(bs, s2.Marshal()) {
+		t.Fatalf("must be deterministic:\n%s", bs)
+	}
+
+	fs2 := afero.NewMemMapFs()
+	if err := s2.Restore(fs2, "/copy"); err != nil {
+		t.Fatal(err)
+	}
+	s3, err := ose.SnapshotFs(fs2, "/copy", &ose.SnapshotOptions{ModTime: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := ose.DiffFsSnapshots(s, s3); diff != "" {
+		t.Fatalf("must be equal:\n%s", diff)
+	}
+}
+
+func TestDiffFsSnapshots(t *testing.T) {
+	fs := newSnapshotFs()
+	s, err := ose.SnapshotFs(fs, "/root", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = afero.WriteFile(fs, "/root/a/b/text.txt", []byte("foo\nBAR\nbaz\n"), 0644)
+	_ = fs.Chmod("/root/noeol.txt", 0644)
+	_ = fs.Remove("/root/marker.txt")
+	_ = afero.WriteFile(fs, "/root/new.txt", []byte("new\n"), 0644)
+	s2, err := ose.SnapshotFs(fs, "/root", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `~ a/b/text.txt: content
+     foo
+    -bar
+    +BAR
+     baz
+- f 0644 marker.txt (11 bytes)
++ f 0644 new.txt (4 bytes)
+~ noeol.txt: mode 0600 -> 0644
+`
+	if diff := ose.DiffFsSnapshots(s, s2); diff != expected {
+		t.Fatalf("invalid diff:\n%s", diff)
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	sb := new(strings.Builder)
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(sb, "line %d\n", i)
+	}
+	s := sb.String()
+	u := strings.Replace(s, "line 50000\n", "LINE 50000\n", 1) + "end\n"
+	expected := `    ...
+     line 49998
+     line 49999
+    -line 50000
+    +LINE 50000
+     line 50001
+     line 50002
+    ...
+     line 99998
+     line 99999
+    +end
+`
+	if diff := ose.DiffLines(s, u); diff != expected {
+		t.Fatalf("invalid diff:\n%s", diff)
+	}
+}
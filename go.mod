@@ -4,18 +4,23 @@ go 1.13
 
 require (
 	github.com/iancoleman/strcase v0.0.0-20191112232945-16388991a334
+	github.com/klauspost/compress v1.10.3
 	github.com/mattn/go-colorable v0.1.6
 	github.com/mitchellh/go-homedir v1.1.0
+	github.com/pelletier/go-toml v1.2.0
 	github.com/rakyll/statik v0.1.7
 	github.com/spf13/afero v1.2.2
 	github.com/spf13/cobra v0.0.6
 	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.6.2
+	github.com/ulikunitz/xz v0.5.7
 	go.uber.org/multierr v1.5.0 // indirect
 	go.uber.org/zap v1.14.0
+	golang.org/x/crypto v0.0.0-20200302210943-78000ba7a073
 	golang.org/x/lint v0.0.0-20200130185559-910be7a94367 // indirect
 	golang.org/x/sys v0.0.0-20200301040627-c5d0d7b4ec88 // indirect
-	golang.org/x/text v0.3.2 // indirect
-	golang.org/x/tools v0.0.0-20200228224639-71482053b885 // indirect
+	golang.org/x/text v0.3.2
+	golang.org/x/tools v0.0.0-20200228224639-71482053b885
+	gopkg.in/yaml.v2 v2.2.4
 	honnef.co/go/tools v0.0.1-2020.1.3 // indirect
 )
@@ -0,0 +1,181 @@
+package ose
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// MultiError is a list of errors, such as failures of some inputs of OpenAll.
+type MultiError []error
+
+func (e MultiError) Error() string {
+	ss := make([]string, 0, len(e))
+	for _, err := range e {
+		ss = append(ss, err.Error())
+	}
+	return strings.Join(ss, "\n")
+}
+
+// Unwrap returns the first error.
+func (e MultiError) Unwrap() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e[0]
+}
+
+// pathError annotates err with name unless it is already a *os.PathError.
+func pathError(op, name string, err error) error {
+	if _, ok := err.(*os.PathError); ok {
+		return err
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// OpenAllOptions configures Opener.OpenAllWithOptions.
+type OpenAllOptions struct {
+	// Glob expands names with glob metacharacters by the Fs of the Opener.
+	Glob bool
+	// OnBoundary is called when each input is opened, before it is read.
+	// What it writes to w, like a "==> name <==" header, is read before the input.
+	OnBoundary func(name string, index int, w io.Writer) error
+	// KeepGoing skips inputs which fail, and the failures are returned as a MultiError at the end instead of io.EOF.
+	KeepGoing bool
+}
+
+// OpenAll concatenates inputs like cat. See OpenAllWithOptions.
+func (o *Opener) OpenAll(names []string) (io.ReadCloser, error) {
+	return o.OpenAllWithOptions(names, nil)
+}
+
+// OpenAllWithOptions returns a reader which concatenates inputs in order. Each input is opened lazily.
+// Stdin ("-") is read only at its first occurrence, and no names mean stdin.
+// Errors of inputs are *os.PathError with the names.
+func (o *Opener) OpenAllWithOptions(names []string, opts *OpenAllOptions) (io.ReadCloser, error) {
+	if opts == nil {
+		opts = &OpenAllOptions{}
+	}
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	expanded := make([]string, 0, len(names))
+	stdin := false
+	for _, name := range names {
+		if o.shouldFallback(name) {
+			if !stdin {
+				expanded = append(expanded, name)
+			}
+			stdin = true
+			continue
+		}
+		if !opts.Glob || !hasGlobMeta(name) {
+			expanded = append(expanded, name)
+			continue
+		}
+		if _, b, _ := o.resolveURL(name); b != nil {
+			expanded = append(expanded, name)
+			continue
+		}
+		matches, err := afero.Glob(o.fs, name)
+		if err != nil {
+			return nil, &os.PathError{Op: "glob", Path: name, Err: err}
+		}
+		if len(matches) == 0 {
+			if !opts.KeepGoing {
+				return nil, &os.PathError{Op: "glob", Path: name, Err: os.ErrNotExist}
+			}
+			expanded = append(expanded, name)
+		}
+		expanded = append(expanded, matches...)
+	}
+	return &multiFileReader{o: o, names: expanded, opts: opts, boundary: new(bytes.Buffer)}, nil
+}
+
+func hasGlobMeta(name string) bool {
+	return strings.ContainsAny(name, `*?[`)
+}
+
+type multiFileReader struct {
+	o        *Opener
+	names    []string
+	opts     *OpenAllOptions
+	index    int
+	current  io.ReadCloser
+	boundary *bytes.Buffer
+	errs     MultiError
+	err      error
+}
+
+func (r *multiFileReader) fail(op string, err error) error {
+	err = pathError(op, r.names[r.index], err)
+	r.index++
+	if r.opts.KeepGoing {
+		r.errs = append(r.errs, err)
+		return nil
+	}
+	r.err = err
+	return err
+}
+
+func (r *multiFileReader) Read(p []byte) (int, error) {
+	for r.err == nil {
+		if r.boundary.Len() != 0 {
+			return r.boundary.Read(p)
+		}
+		if r.current == nil {
+			if r.index >= len(r.names) {
+				if len(r.errs) != 0 {
+					r.err = r.errs
+				} else {
+					r.err = io.EOF
+				}
+				break
+			}
+			name := r.names[r.index]
+			rc, err := r.o.Open(name)
+			if err != nil {
+				if err := r.fail("open", err); err != nil {
+					return 0, err
+				}
+				continue
+			}
+			r.current = rc
+			if r.opts.OnBoundary != nil {
+				err = r.opts.OnBoundary(name, r.index, r.boundary)
+				if err != nil {
+					r.err = err
+					return 0, err
+				}
+			}
+			continue
+		}
+		n, err := r.current.Read(p)
+		if err != nil {
+			_ = r.current.Close()
+			r.current = nil
+			if err == io.EOF {
+				r.index++
+			} else if err := r.fail("read", err); err != nil {
+				return n, err
+			}
+			if n == 0 {
+				continue
+			}
+		}
+		return n, nil
+	}
+	return 0, r.err
+}
+
+func (r *multiFileReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
@@ -0,0 +1,115 @@
+package ose_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+func newOpenAllWorld() *ose.FakeWorld {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "a.txt", []byte("a\n"), 0644)
+	afero.WriteFile(w.FakeFs, "b.txt", []byte("b\n"), 0644)
+	afero.WriteFile(w.FakeFs, "c.log", []byte("c\n"), 0644)
+	w.FakeIO.WriteInString("in\n")
+	return w
+}
+
+func TestOpenerOpenAll(t *testing.T) {
+	w := newOpenAllWorld()
+	o := ose.NewOpenerInWorld(w)
+	rc, err := o.OpenAll([]string{"b.txt", "-", "a.txt", "-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	bs, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "b\nin\na\n" {
+		t.Fatalf("invalid content: %q", bs)
+	}
+
+	w = newOpenAllWorld()
+	o = ose.NewOpenerInWorld(w)
+	rc, err = o.OpenAll(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, _ = ioutil.ReadAll(rc)
+	if string(bs) != "in\n" {
+		t.Fatalf("invalid content: %q", bs)
+	}
+}
+
+func TestOpenerOpenAllGlob(t *testing.T) {
+	w := newOpenAllWorld()
+	o := ose.NewOpenerInWorld(w)
+	opts := &ose.OpenAllOptions{
+		Glob: true,
+		OnBoundary: func(name string, index int, w io.Writer) error {
+			_, err := fmt.Fprintf(w, "==> %s (%d) <==\n", name, index)
+			return err
+		},
+	}
+	rc, err := o.OpenAllWithOptions([]string{"*.txt", "c.log"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "==> a.txt (0) <==\na\n==> b.txt (1) <==\nb\n==> c.log (2) <==\nc\n"
+	if string(bs) != expected {
+		t.Fatalf("invalid content: %q", bs)
+	}
+
+	_, err = o.OpenAllWithOptions([]string{"*.csv"}, opts)
+	if !os.IsNotExist(errors.Unwrap(err)) {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
+
+func TestOpenerOpenAllError(t *testing.T) {
+	w := newOpenAllWorld()
+	o := ose.NewOpenerInWorld(w)
+	rc, err := o.OpenAll([]string{"a.txt", "missing.txt", "b.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := ioutil.ReadAll(rc)
+	var perr *os.PathError
+	if !errors.As(err, &perr) || perr.Path != "missing.txt" {
+		t.Fatalf("invalid error: %v", err)
+	}
+	if string(bs) != "a\n" {
+		t.Fatalf("invalid content: %q", bs)
+	}
+
+	rc, err = o.OpenAllWithOptions([]string{"a.txt", "missing.txt", "b.txt", "missing.log"}, &ose.OpenAllOptions{KeepGoing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err = ioutil.ReadAll(rc)
+	if string(bs) != "a\nb\n" {
+		t.Fatalf("invalid content: %q", bs)
+	}
+	merr, ok := err.(ose.MultiError)
+	if !ok || len(merr) != 2 {
+		t.Fatalf("invalid error: %v", err)
+	}
+	if !errors.As(merr[1], &perr) || perr.Path != "missing.log" {
+		t.Fatalf("invalid error: %v", merr[1])
+	}
+	if !errors.Is(merr, os.ErrNotExist) {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
@@ -2,9 +2,12 @@ package ose
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/spf13/afero"
 )
@@ -25,7 +28,7 @@ func newBufferedWriter(wc io.WriteCloser) io.WriteCloser {
 		}
 		err2 := wc.Close()
 		if err2 != nil {
-			return fmt.Errorf("clise : %w", err)
+			return fmt.Errorf("close : %w", err2)
 		}
 		return nil
 	})
@@ -36,8 +39,51 @@ type Opener struct {
 	FallbackWriter        io.Writer
 	TreatHyphenAsFileName bool
 	Unbuffered            bool
-	fs                    afero.Fs
-	io                    IO
+	// AutoCompression makes Open decompress data by its magic bytes, and Create compress data by the extension of the name.
+	AutoCompression bool
+	// Compression is the name of a codec with which Open and Create always (de)compress data, including stdin and stdout.
+	Compression string
+	// ResolveURLs makes Open and Create dispatch names with a registered scheme, like https://..., to OpenerBackends.
+	ResolveURLs bool
+	// HTTPClient is used by HTTPBackend. http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+	// Durable makes CreateTempFile and Rewrite sync the data and the directory. See TempScope.
+	Durable bool
+	// Progress receives the progress of reading and writing if not nil. Clock measures the elapsed time.
+	Progress ProgressSink
+	Clock    Clock
+	// RateLimiter limits the bandwidth of reading and writing if not nil. It is shared by all streams of the Opener.
+	RateLimiter *RateLimiter
+	// Timeout makes a read or a write fail with *TimeoutError if it stalls longer than this duration.
+	Timeout time.Duration
+	// ChecksumSidecar makes Create and CreateTempFile write the SHA-256 digest of a file to name.sha256 after it is written.
+	ChecksumSidecar bool
+	// Verify makes Open verify files as OpenVerified with an empty digest.
+	Verify       bool
+	verifyDigest string
+	// Encoding is the charset of text, like "shift_jis", "euc-jp" or "utf-16" (see LookupEncoding).
+	// Open decodes it into UTF-8 stripping a BOM, and Create encodes UTF-8 into it.
+	// "auto" makes Open detect the charset by DetectEncoding from the first Read, and Create write UTF-8 as it is.
+	// Open passes the bytes through if no charset is detected.
+	Encoding string
+	// Newline is "lf" or "crlf". Open converts CRLF to LF for both, and Create converts newlines to it.
+	Newline string
+	// DataFormat is the name of a format used by DecodeFile and EncodeFile if the extension of a name is unknown, like stdin.
+	DataFormat string
+	// SpoolThreshold is the number of bytes which OpenSeekable holds in memory before spooling to a temporary file in SpoolDir.
+	// Zero means DefaultSpoolThreshold, and a negative value means always spooling to a file.
+	SpoolThreshold int64
+	SpoolDir       string
+	// NoClobber makes Create and CreateTempFile fail with *ClobberError instead of overwriting an existing file.
+	NoClobber bool
+	// Backup makes Create and CreateTempFile keep an existing file as a backup before overwriting it.
+	// BackupSuffix is the suffix of simple backups, DefaultBackupSuffix if empty.
+	Backup       BackupMode
+	BackupSuffix string
+	// Append makes Create append data to an existing file, and CreateTempFile write the file with the existing data followed by new data.
+	Append bool
+	fs     afero.Fs
+	io     IO
 }
 
 func NewOpener(fs afero.Fs, io IO) *Opener {
@@ -49,15 +95,117 @@ func NewOpener(fs afero.Fs, io IO) *Opener {
 	}
 }
 
+func NewOpenerInWorld(w World) *Opener {
+	o := NewOpener(w.Fs(), w.IO())
+	o.Clock = w.Clock()
+	return o
+}
+
 func NewOpenerInThisWorld() *Opener {
-	return NewOpener(GetFs(), GetIO())
+	return NewOpenerInWorld(GetWorld())
+}
+
+// NewOpenerWithContext creates an Opener in the World carried by ctx.
+func NewOpenerWithContext(ctx context.Context) *Opener {
+	return NewOpenerInWorld(WorldFrom(ctx))
 }
 
 func (o *Opener) shouldFallback(name string) bool {
 	return name == "" || (!o.TreatHyphenAsFileName && name == "-")
 }
 
+func (o *Opener) codec() (*CompressionCodec, error) {
+	if o.Compression == "" {
+		return nil, nil
+	}
+	c, ok := LookupCompressionCodec(o.Compression)
+	if !ok {
+		return nil, fmt.Errorf("unknown compression: %s", o.Compression)
+	}
+	return c, nil
+}
+
+func (o *Opener) decompress(rc io.ReadCloser) (io.ReadCloser, error) {
+	c, err := o.codec()
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	if c != nil {
+		return newCodecReader(c, rc, rc)
+	}
+	if o.AutoCompression {
+		return NewDecompressingReader(rc)
+	}
+	return rc, nil
+}
+
+func (o *Opener) codecForCreate(name string) (*CompressionCodec, error) {
+	c, err := o.codec()
+	if err != nil {
+		return nil, err
+	}
+	if c == nil && o.AutoCompression && !o.shouldFallback(name) {
+		c = CompressionCodecForFileName(name)
+	}
+	if c != nil && c.NewWriter == nil {
+		return nil, fmt.Errorf("%s: compression is not supported", c.Name)
+	}
+	return c, nil
+}
+
+func (o *Opener) compress(c *CompressionCodec, wc io.WriteCloser) (io.WriteCloser, error) {
+	if c == nil {
+		return wc, nil
+	}
+	return NewCompressingWriter(c, wc)
+}
+
 func (o *Opener) openFile(name string, ff func(name string) (afero.File, error)) (io.ReadCloser, error) {
+	u, b, err := o.resolveURL(name)
+	if err != nil {
+		return nil, err
+	}
+	var rc io.ReadCloser
+	if fb, ok := b.(FileBackend); ok {
+		rc, err = fb.openFile(o, u, ff)
+	} else if b != nil {
+		rc, err = b.Open(o, u)
+	} else {
+		rc, err = o.openRawFile(name, ff)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.verifyDigest != "" || (o.Verify && !o.shouldFallback(name)) {
+		rc, err = o.verify(name, rc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.Timeout > 0 {
+		rc = NewTimeoutReadCloser(rc, o.Timeout, o.Clock)
+	}
+	if o.RateLimiter != nil {
+		rc = NewRateLimitedReadCloser(rc, o.RateLimiter)
+	}
+	if o.Progress != nil {
+		total := int64(-1)
+		if b == nil && !o.shouldFallback(name) {
+			if fi, err := o.fs.Stat(name); err == nil && fi.Mode().IsRegular() {
+				total = fi.Size()
+			}
+		}
+		rc = NewProgressReadCloser(rc, name, total, o.Clock, o.Progress)
+	}
+	rc, err = o.decompress(rc)
+	if err != nil {
+		return nil, err
+	}
+	return o.decodeText(rc)
+}
+
+func (o *Opener) openRawFile(name string, ff func(name string) (afero.File, error)) (io.ReadCloser, error) {
 	if o.shouldFallback(name) {
 		if o.Unbuffered {
 			return NopReadCloser(o.FallbackReader), nil
@@ -84,6 +232,48 @@ func (o *Opener) Open(name string) (io.ReadCloser, error) {
 }
 
 func (o *Opener) createFile(name string, ff func(name string) (afero.File, error)) (io.WriteCloser, error) {
+	c, err := o.codecForCreate(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := o.textOptions(); err != nil {
+		return nil, err
+	}
+	u, b, err := o.resolveURL(name)
+	if err != nil {
+		return nil, err
+	}
+	var wc io.WriteCloser
+	if fb, ok := b.(FileBackend); ok {
+		wc, err = fb.createFile(o, u, ff)
+	} else if b != nil {
+		wc, err = b.Create(o, u)
+	} else {
+		wc, err = o.createRawFile(name, ff)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.ChecksumSidecar && b == nil && !o.shouldFallback(name) {
+		wc = o.withSidecar(name, wc)
+	}
+	if o.Timeout > 0 {
+		wc = NewTimeoutWriteCloser(wc, o.Timeout, o.Clock)
+	}
+	if o.RateLimiter != nil {
+		wc = NewRateLimitedWriteCloser(wc, o.RateLimiter)
+	}
+	if o.Progress != nil {
+		wc = NewProgressWriteCloser(wc, name, -1, o.Clock, o.Progress)
+	}
+	wc, err = o.compress(c, wc)
+	if err != nil {
+		return nil, err
+	}
+	return o.encodeText(wc)
+}
+
+func (o *Opener) createRawFile(name string, ff func(name string) (afero.File, error)) (io.WriteCloser, error) {
 	if o.shouldFallback(name) {
 		if o.Unbuffered {
 			return NopWriteCloser(o.FallbackWriter), nil
@@ -107,12 +297,15 @@ func (o *Opener) CreateFile(name string, flag int, perm os.FileMode) (io.WriteCl
 	return o.createFile(name, func(name string) (afero.File, error) { return o.fs.OpenFile(name, flag, perm) })
 }
 
+// Create creates a file, or truncates it. NoClobber, Backup and Append change the behavior.
 func (o *Opener) Create(name string) (io.WriteCloser, error) {
-	return o.createFile(name, o.fs.Create)
+	return o.createFile(name, o.createPolicyFile)
 }
 
 func (o *Opener) TempScope() *TempScope {
-	return NewTempScope(o.fs)
+	s := NewTempScope(o.fs)
+	s.Durable = o.Durable
+	return s
 }
 
 func (o *Opener) CreateTempFile(dir, prefix, newname string, handler func(f io.WriteCloser) (bool, error)) (bool, error) {
@@ -124,12 +317,93 @@ func (o *Opener) CreateTempFile(dir, prefix, newname string, handler func(f io.W
 		defer wc.Close()
 		return handler(wc)
 	}
-	return o.TempScope().TempFileScope(dir, prefix, newname, func(f afero.File) (bool, error) {
+	newname, err := o.resolveFilePath("create temp file", newname)
+	if err != nil {
+		return false, err
+	}
+	return o.createTempFile(dir, prefix, newname, "", handler, nil)
+}
+
+// resolveFilePath resolves a file URL to a path, and rejects URLs of the other schemes for op.
+func (o *Opener) resolveFilePath(op, name string) (string, error) {
+	u, b, err := o.resolveURL(name)
+	if err != nil || b == nil {
+		return name, err
+	}
+	if _, ok := b.(FileBackend); !ok {
+		return "", unsupported(op, u)
+	}
+	return FileBackend{}.path(u)
+}
+
+// wrapTempFile returns a writer to w buffered, compressed and encoded as Create does. Closing it does not close w.
+func (o *Opener) wrapTempFile(w io.Writer, c *CompressionCodec) (io.WriteCloser, error) {
+	var wc io.WriteCloser = NopWriteCloser(w)
+	if !o.Unbuffered {
+		wc = newBufferedWriter(wc)
+	}
+	wc, err := o.compress(c, wc)
+	if err != nil {
+		return nil, err
+	}
+	return o.encodeText(wc)
+}
+
+// createTempFile calls commit with the temporary file after handler succeeds and the written data are flushed.
+// If digest is not empty, the file is committed only if its SHA-256 digest equals digest.
+func (o *Opener) createTempFile(dir, prefix, newname, digest string, handler func(f io.WriteCloser) (bool, error), commit func(f afero.File) error) (bool, error) {
+	c, err := o.codecForCreate(newname)
+	if err != nil {
+		return false, err
+	}
+	if _, err := o.textOptions(); err != nil {
+		return false, err
+	}
+	if err := o.checkClobber(newname); err != nil {
+		return false, err
+	}
+	var hwc *HashWriteCloser
+	ok, err := o.TempScope().TempFileScope(dir, prefix, newname, func(f afero.File) (bool, error) {
+		var w io.Writer = f
+		if digest != "" || o.ChecksumSidecar {
+			hwc, _ = NewHashWriteCloser(f, "sha256")
+			w = hwc
+		}
+		if err := o.appendExisting(f, w, newname); err != nil {
+			return false, err
+		}
 		var wc io.WriteCloser = f
-		if !o.Unbuffered {
-			wc = newBufferedWriter(NopWriteCloser(f))
-			defer wc.Close()
+		if !o.Unbuffered || c != nil || hwc != nil || o.Encoding != "" || o.Newline != "" {
+			var err error
+			wc, err = o.wrapTempFile(w, c)
+			if err != nil {
+				return false, err
+			}
 		}
-		return handler(wc)
+		ok, err := handler(wc)
+		if wc != io.WriteCloser(f) {
+			err2 := wc.Close()
+			if err == nil {
+				err = err2
+			}
+		}
+		if !ok || err != nil {
+			return ok, err
+		}
+		if digest != "" {
+			if actual := hwc.SumHex("sha256"); actual != digest {
+				return false, &ChecksumMismatchError{Name: newname, Algorithm: "sha256", Expected: digest, Actual: actual}
+			}
+		}
+		if commit != nil {
+			if err := commit(f); err != nil {
+				return ok, err
+			}
+		}
+		return ok, o.beforeCommit(newname)
 	})
+	if ok && err == nil && o.ChecksumSidecar {
+		err = o.writeSidecar(newname, hwc.SumHex("sha256"))
+	}
+	return ok, err
 }
@@ -0,0 +1,178 @@
+package ose
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// OpenerBackend opens resources of a URL scheme for an Opener.
+type OpenerBackend interface {
+	Open(o *Opener, u *url.URL) (io.ReadCloser, error)
+	Create(o *Opener, u *url.URL) (io.WriteCloser, error)
+}
+
+// UnsupportedOperationError is returned when an OpenerBackend does not support an operation, like writing to http.
+type UnsupportedOperationError struct {
+	Op     string
+	Scheme string
+	URL    string
+}
+
+func (e *UnsupportedOperationError) Error() string {
+	return fmt.Sprintf("%s %s: not supported by scheme %s", e.Op, e.URL, e.Scheme)
+}
+
+func unsupported(op string, u *url.URL) error {
+	return &UnsupportedOperationError{Op: op, Scheme: u.Scheme, URL: u.String()}
+}
+
+var (
+	openerBackendsMutex sync.RWMutex
+	openerBackends      = make(map[string]OpenerBackend)
+)
+
+// RegisterOpenerBackend registers b for scheme, replacing the backend registered before.
+func RegisterOpenerBackend(scheme string, b OpenerBackend) {
+	openerBackendsMutex.Lock()
+	defer openerBackendsMutex.Unlock()
+	openerBackends[strings.ToLower(scheme)] = b
+}
+
+// LookupOpenerBackend returns the backend registered for scheme.
+func LookupOpenerBackend(scheme string) (OpenerBackend, bool) {
+	openerBackendsMutex.RLock()
+	defer openerBackendsMutex.RUnlock()
+	b, ok := openerBackends[strings.ToLower(scheme)]
+	return b, ok
+}
+
+// resolveURL returns the URL and its backend if ResolveURLs is enabled and name has a registered scheme.
+// A single letter scheme is regarded as a drive letter.
+func (o *Opener) resolveURL(name string) (*url.URL, OpenerBackend, error) {
+	if !o.ResolveURLs {
+		return nil, nil, nil
+	}
+	i := strings.Index(name, ":")
+	if i < 2 {
+		return nil, nil, nil
+	}
+	b, ok := LookupOpenerBackend(name[:i])
+	if !ok {
+		return nil, nil, nil
+	}
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return u, b, nil
+}
+
+// FileBackend opens file URLs in the Fs of an Opener.
+// The flag and perm of Opener.OpenFile and Opener.CreateFile, and the write policies of Opener.Create apply to the files.
+type FileBackend struct{}
+
+func (FileBackend) path(u *url.URL) (string, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("%s: remote file URLs are not supported", u)
+	}
+	if u.Opaque != "" {
+		return u.Opaque, nil
+	}
+	return u.Path, nil
+}
+
+func (b FileBackend) Open(o *Opener, u *url.URL) (io.ReadCloser, error) {
+	return b.openFile(o, u, o.fs.Open)
+}
+
+func (b FileBackend) Create(o *Opener, u *url.URL) (io.WriteCloser, error) {
+	return b.createFile(o, u, o.createPolicyFile)
+}
+
+// openFile opens the file of u by ff, which carries the flag and perm of Opener.OpenFile.
+func (b FileBackend) openFile(o *Opener, u *url.URL, ff func(name string) (afero.File, error)) (io.ReadCloser, error) {
+	p, err := b.path(u)
+	if err != nil {
+		return nil, err
+	}
+	return o.openRawFile(p, ff)
+}
+
+// createFile creates the file of u by ff, which carries the flag and perm of Opener.CreateFile or the write policies of Opener.Create.
+func (b FileBackend) createFile(o *Opener, u *url.URL, ff func(name string) (afero.File, error)) (io.WriteCloser, error) {
+	p, err := b.path(u)
+	if err != nil {
+		return nil, err
+	}
+	return o.createRawFile(p, ff)
+}
+
+// HTTPBackend gets http and https URLs by the HTTPClient of an Opener, or http.DefaultClient.
+type HTTPBackend struct{}
+
+func (HTTPBackend) Open(o *Opener, u *url.URL) (io.ReadCloser, error) {
+	client := o.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || 300 <= resp.StatusCode {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (HTTPBackend) Create(o *Opener, u *url.URL) (io.WriteCloser, error) {
+	return nil, unsupported("create", u)
+}
+
+// DataBackend reads data URLs (RFC 2397).
+type DataBackend struct{}
+
+func (DataBackend) Open(o *Opener, u *url.URL) (io.ReadCloser, error) {
+	s := u.Opaque
+	i := strings.Index(s, ",")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid data URL: %s", u)
+	}
+	header, data := s[:i], s[i+1:]
+	var bs []byte
+	var err error
+	if strings.HasSuffix(header, ";base64") {
+		bs, err = base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			bs, err = base64.RawStdEncoding.DecodeString(data)
+		}
+	} else {
+		data, err = url.PathUnescape(data)
+		bs = []byte(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid data URL: %w", err)
+	}
+	return ioutil.NopCloser(bytes.NewReader(bs)), nil
+}
+
+func (DataBackend) Create(o *Opener, u *url.URL) (io.WriteCloser, error) {
+	return nil, unsupported("create", u)
+}
+
+func init() {
+	RegisterOpenerBackend("file", FileBackend{})
+	RegisterOpenerBackend("http", HTTPBackend{})
+	RegisterOpenerBackend("https", HTTPBackend{})
+	RegisterOpenerBackend("data", DataBackend{})
+}
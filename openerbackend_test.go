@@ -0,0 +1,216 @@
+package ose_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+func TestOpenerFileURL(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.ResolveURLs = true
+	wc, err := o.Create("file:///tmp/foo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "foo")
+	wc.Close()
+	bs, err := afero.ReadFile(w.FakeFs, "/tmp/foo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "foo" {
+		t.Fatalf("invalid content: %q", bs)
+	}
+	if s := readAll(t, o, "file://localhost/tmp/foo.txt"); s != "foo" {
+		t.Fatalf("invalid content: %q", s)
+	}
+	if _, err := o.Open("file://example.com/tmp/foo.txt"); err == nil {
+		t.Fatal("must fail")
+	}
+
+	o.ResolveURLs = false
+	if _, err := o.Open("file:///tmp/foo.txt"); err == nil {
+		t.Fatal("must be a path")
+	}
+}
+
+func TestOpenerFileURLPolicies(t *testing.T) {
+	newOpener := func() (*ose.FakeWorld, *ose.Opener) {
+		w := ose.NewFakeWorld()
+		afero.WriteFile(w.FakeFs, "/tmp/foo.txt", []byte("old\n"), 0644)
+		o := ose.NewOpenerInWorld(w)
+		o.ResolveURLs = true
+		return w, o
+	}
+
+	w, o := newOpener()
+	o.NoClobber = true
+	err := writeString(t, o, "file:///tmp/foo.txt", "new\n")
+	var cerr *ose.ClobberError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("invalid error: %v", err)
+	}
+	assertFile(t, w.FakeFs, "/tmp/foo.txt", "old\n")
+
+	w, o = newOpener()
+	o.Backup = ose.BackupSimple
+	err = writeString(t, o, "file:///tmp/foo.txt", "new\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, w.FakeFs, "/tmp/foo.txt", "new\n")
+	assertFile(t, w.FakeFs, "/tmp/foo.txt~", "old\n")
+
+	w, o = newOpener()
+	o.Append = true
+	err = writeString(t, o, "file:///tmp/foo.txt", "new\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, w.FakeFs, "/tmp/foo.txt", "old\nnew\n")
+}
+
+func TestOpenerFileURLFlags(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "/tmp/foo.txt", []byte("old\n"), 0644)
+	o := ose.NewOpenerInWorld(w)
+	o.ResolveURLs = true
+	wc, err := o.CreateFile("file:///tmp/foo.txt", os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "new\n")
+	wc.Close()
+	assertFile(t, w.FakeFs, "/tmp/foo.txt", "old\nnew\n")
+
+	wc, err = o.CreateFile("file:///tmp/bar.txt", os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wc.Close()
+	fi, err := w.FakeFs.Stat("/tmp/bar.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("invalid mode: %v", fi.Mode())
+	}
+
+	if _, err := o.OpenFile("file:///tmp/baz.txt", os.O_RDONLY, 0); !os.IsNotExist(err) {
+		t.Fatalf("invalid error: %v", err)
+	}
+	rc, err := o.OpenFile("file:///tmp/baz.txt", os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+	if ok, _ := afero.Exists(w.FakeFs, "/tmp/baz.txt"); !ok {
+		t.Fatal("must be created")
+	}
+}
+
+func TestOpenerHTTPURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/data.json" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `{"foo":"bar"}`)
+	}))
+	defer ts.Close()
+
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.ResolveURLs = true
+	o.HTTPClient = ts.Client()
+	if s := readAll(t, o, ts.URL+"/data.json"); s != `{"foo":"bar"}` {
+		t.Fatalf("invalid content: %q", s)
+	}
+	if _, err := o.Open(ts.URL + "/missing.json"); err == nil {
+		t.Fatal("must fail")
+	}
+
+	_, err := o.Create(ts.URL + "/data.json")
+	var uerr *ose.UnsupportedOperationError
+	if !errors.As(err, &uerr) {
+		t.Fatalf("invalid error: %v", err)
+	}
+	if uerr.Op != "create" || uerr.Scheme != "http" {
+		t.Fatalf("invalid error: %+v", uerr)
+	}
+	_, err = o.CreateTempFile("", "opener", ts.URL+"/data.json", func(f io.WriteCloser) (bool, error) {
+		t.Fatal("must not be called")
+		return false, nil
+	})
+	if !errors.As(err, &uerr) {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
+
+func TestOpenerDataURL(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.ResolveURLs = true
+	if s := readAll(t, o, "data:text/plain;base64,aGVsbG8="); s != "hello" {
+		t.Fatalf("invalid content: %q", s)
+	}
+	if s := readAll(t, o, "data:,hello%2C%20world"); s != "hello, world" {
+		t.Fatalf("invalid content: %q", s)
+	}
+	if _, err := o.Open("data:text/plain"); err == nil {
+		t.Fatal("must fail")
+	}
+	var uerr *ose.UnsupportedOperationError
+	if _, err := o.Create("data:,foo"); !errors.As(err, &uerr) {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
+
+type memBackend struct {
+	files map[string]*bytes.Buffer
+}
+
+func (b *memBackend) Open(o *ose.Opener, u *url.URL) (io.ReadCloser, error) {
+	buf, ok := b.files[u.Host+u.Path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return ioutil.NopCloser(bytes.NewReader(buf.Bytes())), nil
+}
+
+func (b *memBackend) Create(o *ose.Opener, u *url.URL) (io.WriteCloser, error) {
+	buf := new(bytes.Buffer)
+	b.files[u.Host+u.Path] = buf
+	return ose.NopWriteCloser(buf), nil
+}
+
+func TestRegisterOpenerBackend(t *testing.T) {
+	ose.RegisterOpenerBackend("mem", &memBackend{files: make(map[string]*bytes.Buffer)})
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.ResolveURLs = true
+	o.AutoCompression = true
+	wc, err := o.Create("mem://bucket/foo.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "foo")
+	wc.Close()
+	if s := readAll(t, o, "mem://bucket/foo.gz"); s != "foo" {
+		t.Fatalf("invalid content: %q", s)
+	}
+	if _, err := o.Open("unknown://foo"); err == nil {
+		t.Fatal("unknown schemes must be paths")
+	}
+}
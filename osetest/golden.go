@@ -0,0 +1,77 @@
+// Package osetest provides utilities for testing with ose.
+package osetest
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+// update is namespaced so that it doesn't conflict with the -update flag of test packages.
+var update = flag.Bool("ose.update", false, "update golden files under testdata")
+
+// Update reports whether golden files should be updated, which is enabled by go test -ose.update.
+func Update() bool { return *update }
+
+// GoldenPath returns the path of the golden file for name.
+func GoldenPath(name string) string {
+	return filepath.Join("testdata", name+".golden")
+}
+
+func writeGolden(t testing.TB, fpath string, actual []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fpath, actual, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// AssertGolden compares actual with testdata/name.golden.
+func AssertGolden(t testing.TB, name string, actual []byte) {
+	t.Helper()
+	fpath := GoldenPath(name)
+	if Update() {
+		writeGolden(t, fpath, actual)
+		return
+	}
+	expected, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		t.Fatalf("can't read golden file (run with -ose.update to create it): %v", err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("mismatch with %s:\n%s", fpath, ose.DiffLines(string(expected), string(actual)))
+	}
+}
+
+// AssertFsGolden compares the snapshot of fs under root with testdata/name.golden.
+func AssertFsGolden(t testing.TB, fs afero.Fs, root string, name string, opts *ose.SnapshotOptions) {
+	t.Helper()
+	actual, err := ose.SnapshotFs(fs, root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fpath := GoldenPath(name)
+	if Update() {
+		writeGolden(t, fpath, actual.Marshal())
+		return
+	}
+	bs, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		t.Fatalf("can't read golden file (run with -ose.update to create it): %v", err)
+	}
+	expected, err := ose.UnmarshalFsSnapshot(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := ose.DiffFsSnapshots(expected, actual); diff != "" {
+		t.Errorf("mismatch with %s:\n%s", fpath, diff)
+	}
+}
@@ -0,0 +1,21 @@
+package osetest_test
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+	"github.com/taskie/ose/osetest"
+)
+
+func TestAssertFsGolden(t *testing.T) {
+	w := ose.NewFakeWorld()
+	_ = w.Fs().MkdirAll("/work/sub", 0755)
+	_ = afero.WriteFile(w.Fs(), "/work/sub/foo.txt", []byte("foo\nbar\n"), 0644)
+	_ = afero.WriteFile(w.Fs(), "/work/bin", []byte{0, 1, 2}, 0600)
+	osetest.AssertFsGolden(t, w.Fs(), "/work", "fs", nil)
+}
+
+func TestAssertGolden(t *testing.T) {
+	osetest.AssertGolden(t, "text", []byte("hello\n"))
+}
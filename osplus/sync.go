@@ -0,0 +1,29 @@
+package osplus
+
+import "errors"
+
+// SyncCloser is a file which can be synced, such as *os.File and afero.File.
+type SyncCloser interface {
+	Sync() error
+	Close() error
+}
+
+// SyncClose syncs and closes f. It ignores errors of platforms which can't sync f, such as directories.
+func SyncClose(f SyncCloser) error {
+	err := f.Sync()
+	err2 := f.Close()
+	if err != nil && !IsSyncUnsupported(err) {
+		return err
+	}
+	return err2
+}
+
+// IsSyncUnsupported reports whether err means that the file can't be synced on this platform.
+func IsSyncUnsupported(err error) bool {
+	for _, target := range syncUnsupportedErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+// +build !windows
+
+package osplus
+
+import "syscall"
+
+var syncUnsupportedErrors = []error{syscall.EINVAL, syscall.ENOTSUP, syscall.EBADF}
@@ -0,0 +1,9 @@
+package osplus
+
+import "syscall"
+
+// errorInvalidHandle is ERROR_INVALID_HANDLE, which syscall doesn't define.
+const errorInvalidHandle = syscall.Errno(6)
+
+// FlushFileBuffers fails on a directory opened read-only.
+var syncUnsupportedErrors = []error{syscall.ERROR_ACCESS_DENIED, errorInvalidHandle, syscall.EINVAL}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 type TempFile struct {
@@ -11,7 +12,9 @@ type TempFile struct {
 	Dir         string
 	Pattern     string
 	MoveOptions *MoveOptions
-	File        *os.File
+	// Durable makes Close sync the file before moving it, and the directory of Destination after moving it.
+	Durable bool
+	File    *os.File
 }
 
 func CreateTempFile(tempDir, tempPattern string) (*TempFile, error) {
@@ -49,6 +52,9 @@ func (tmp *TempFile) Name() string {
 
 func (tmp *TempFile) CloseFile() error {
 	if tmp.File != nil {
+		if tmp.Durable && tmp.Destination != "" {
+			return SyncClose(tmp.File)
+		}
 		return tmp.File.Close()
 	}
 	return fmt.Errorf("tempfile is not opened yet")
@@ -68,7 +74,11 @@ func (tmp *TempFile) Close() error {
 		if err1 != nil {
 			return err1
 		}
-		return tmp.move(tmp.Destination)
+		err := tmp.move(tmp.Destination)
+		if err != nil || !tmp.Durable {
+			return err
+		}
+		return SyncDir(filepath.Dir(tmp.Destination))
 	}
 	return fmt.Errorf("tempfile is not opened yet")
 }
@@ -93,3 +103,13 @@ func (tmp *TempFile) remove() error {
 	}
 	return fmt.Errorf("tempfile is not opened yet")
 }
+
+// SyncDir syncs a directory so that renames and creations in it persist.
+// It does nothing on platforms which can't sync directories.
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	return SyncClose(d)
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
 
 	"github.com/taskie/ose/osplus"
@@ -72,3 +73,51 @@ func testCreateTempFileWithDestination(t *testing.T) {
 		t.Fatalf("%s must not exist", tf.File.Name())
 	}
 }
+
+func TestCreateTempFileDurable(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "osplus-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	fooPath := filepath.Join(tmp, "foo")
+	tf, err := osplus.CreateTempFileWithDestination(fooPath, tmp, "osplus-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tf.Durable = true
+	_, err = tf.Write([]byte("ABC"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := ioutil.ReadFile(fooPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "ABC" {
+		t.Fatalf("invalid value: %v", bs)
+	}
+}
+
+func TestSyncDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "osplus-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = osplus.SyncDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !osplus.IsSyncUnsupported(&os.PathError{Op: "sync", Path: dir, Err: syscall.EINVAL}) {
+		t.Fatal("EINVAL must mean unsupported")
+	}
+	if osplus.IsSyncUnsupported(&os.PathError{Op: "sync", Path: dir, Err: syscall.EIO}) {
+		t.Fatal("EIO must not mean unsupported")
+	}
+}
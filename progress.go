@@ -0,0 +1,249 @@
+package ose
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress is a snapshot of a transfer.
+type Progress struct {
+	Name string
+	// Current is the number of bytes transferred.
+	Current int64
+	// Total is the number of bytes to transfer, or -1 if unknown.
+	Total   int64
+	Elapsed time.Duration
+	Done    bool
+}
+
+// Rate returns bytes per second.
+func (p Progress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Current) / p.Elapsed.Seconds()
+}
+
+// ETA estimates the remaining time. It returns false if unknown.
+func (p Progress) ETA() (time.Duration, bool) {
+	rate := p.Rate()
+	if p.Total < 0 || rate <= 0 {
+		return 0, false
+	}
+	remaining := p.Total - p.Current
+	if remaining < 0 {
+		remaining = 0
+	}
+	return time.Duration(float64(remaining) / rate * float64(time.Second)), true
+}
+
+// ProgressSink receives progress reports.
+type ProgressSink interface {
+	ReportProgress(p Progress)
+}
+
+// ProgressFunc is a function as a ProgressSink.
+type ProgressFunc func(p Progress)
+
+func (f ProgressFunc) ReportProgress(p Progress) { f(p) }
+
+// DefaultProgressInterval is the Interval of a new ProgressTracker.
+const DefaultProgressInterval = 100 * time.Millisecond
+
+// ProgressTracker counts bytes and reports the progress to Sink at most once per Interval.
+// The last progress is always reported by Finish.
+type ProgressTracker struct {
+	Name     string
+	Total    int64
+	Interval time.Duration
+	Sink     ProgressSink
+	clock    Clock
+	mutex    sync.Mutex
+	current  int64
+	start    time.Time
+	last     time.Time
+	done     bool
+}
+
+// NewProgressTracker starts tracking. A nil clock means the real clock.
+func NewProgressTracker(name string, total int64, clock Clock, sink ProgressSink) *ProgressTracker {
+	if clock == nil {
+		clock = realClock{}
+	}
+	now := clock.Now()
+	return &ProgressTracker{Name: name, Total: total, Interval: DefaultProgressInterval, Sink: sink, clock: clock, start: now, last: now}
+}
+
+func (t *ProgressTracker) progress(now time.Time) Progress {
+	return Progress{Name: t.Name, Current: t.current, Total: t.Total, Elapsed: now.Sub(t.start), Done: t.done}
+}
+
+// Add counts n bytes.
+func (t *ProgressTracker) Add(n int) {
+	t.mutex.Lock()
+	if t.done {
+		t.mutex.Unlock()
+		return
+	}
+	t.current += int64(n)
+	now := t.clock.Now()
+	if now.Sub(t.last) < t.Interval {
+		t.mutex.Unlock()
+		return
+	}
+	t.last = now
+	p := t.progress(now)
+	t.mutex.Unlock()
+	t.Sink.ReportProgress(p)
+}
+
+// Finish reports the last progress. It does nothing if already finished.
+func (t *ProgressTracker) Finish() {
+	t.mutex.Lock()
+	if t.done {
+		t.mutex.Unlock()
+		return
+	}
+	t.done = true
+	p := t.progress(t.clock.Now())
+	t.mutex.Unlock()
+	t.Sink.ReportProgress(p)
+}
+
+// ProgressReadCloser implements io.ReadCloser.
+// It reports the bytes read from the underlying ReadCloser, and finishes at EOF or Close.
+type ProgressReadCloser struct {
+	ReadCloser io.ReadCloser
+	Tracker    *ProgressTracker
+}
+
+// NewProgressReadCloser tracks rc. total is -1 if unknown.
+func NewProgressReadCloser(rc io.ReadCloser, name string, total int64, clock Clock, sink ProgressSink) *ProgressReadCloser {
+	return &ProgressReadCloser{ReadCloser: rc, Tracker: NewProgressTracker(name, total, clock, sink)}
+}
+
+func (prc *ProgressReadCloser) Read(p []byte) (int, error) {
+	n, err := prc.ReadCloser.Read(p)
+	prc.Tracker.Add(n)
+	if err == io.EOF {
+		prc.Tracker.Finish()
+	}
+	return n, err
+}
+
+func (prc *ProgressReadCloser) Close() error {
+	prc.Tracker.Finish()
+	return prc.ReadCloser.Close()
+}
+
+// ProgressWriteCloser implements io.WriteCloser.
+// It reports the bytes written to the underlying WriteCloser, and finishes at Close.
+type ProgressWriteCloser struct {
+	WriteCloser io.WriteCloser
+	Tracker     *ProgressTracker
+}
+
+// NewProgressWriteCloser tracks wc. total is -1 if unknown.
+func NewProgressWriteCloser(wc io.WriteCloser, name string, total int64, clock Clock, sink ProgressSink) *ProgressWriteCloser {
+	return &ProgressWriteCloser{WriteCloser: wc, Tracker: NewProgressTracker(name, total, clock, sink)}
+}
+
+func (pwc *ProgressWriteCloser) Write(p []byte) (int, error) {
+	n, err := pwc.WriteCloser.Write(p)
+	pwc.Tracker.Add(n)
+	return n, err
+}
+
+func (pwc *ProgressWriteCloser) Close() error {
+	err := pwc.WriteCloser.Close()
+	pwc.Tracker.Finish()
+	return err
+}
+
+// ProgressBar renders progress as a bar on Err of an IO, only if it is a terminal.
+type ProgressBar struct {
+	io    IO
+	mutex sync.Mutex
+}
+
+func NewProgressBar(io IO) *ProgressBar {
+	return &ProgressBar{io: io}
+}
+
+func NewProgressBarInWorld(w World) *ProgressBar {
+	return NewProgressBar(w.IO())
+}
+
+func (b *ProgressBar) ReportProgress(p Progress) {
+	if !IsTerminal(b.io, StreamErr) {
+		return
+	}
+	width, _, err := TerminalSize(b.io, StreamErr)
+	if err != nil || width <= 0 {
+		width = 80
+	}
+	line := RenderProgress(p, width-1)
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if p.Done {
+		fmt.Fprintf(b.io.Err(), "\r%s\n", line)
+	} else {
+		fmt.Fprintf(b.io.Err(), "\r%s", line)
+	}
+}
+
+// RenderProgress formats p like "name [=====>    ]  50% 1.0MiB/2.0MiB 512.0KiB/s ETA 2s" within width.
+func RenderProgress(p Progress, width int) string {
+	stats := formatBytes(p.Current)
+	if p.Total >= 0 {
+		stats += "/" + formatBytes(p.Total)
+	}
+	stats += " " + formatBytes(int64(p.Rate())) + "/s"
+	if p.Done {
+		stats += " " + p.Elapsed.Round(time.Second).String()
+	} else if eta, ok := p.ETA(); ok {
+		stats += " ETA " + eta.Round(time.Second).String()
+	}
+	if p.Total > 0 {
+		percent := p.Current * 100 / p.Total
+		if percent > 100 {
+			percent = 100
+		}
+		stats = fmt.Sprintf("%3d%% %s", percent, stats)
+		barWidth := width - len(p.Name) - len(stats) - 4
+		if barWidth >= 10 {
+			filled := int(int64(barWidth) * percent / 100)
+			bar := strings.Repeat("=", filled)
+			if filled < barWidth {
+				bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+			}
+			stats = "[" + bar + "] " + stats
+		}
+	}
+	line := stats
+	if p.Name != "" {
+		line = p.Name + " " + stats
+	}
+	if len(line) > width {
+		line = line[len(line)-width:]
+	} else {
+		line += strings.Repeat(" ", width-len(line))
+	}
+	return line
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,194 @@
+package ose_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+func TestProgressReadCloser(t *testing.T) {
+	clock := ose.NewFakeClock(epoch, time.Second)
+	ps := make([]ose.Progress, 0)
+	sink := ose.ProgressFunc(func(p ose.Progress) { ps = append(ps, p) })
+	rc := ose.NewProgressReadCloser(ioutil.NopCloser(strings.NewReader("0123456789")), "foo", 10, clock, sink)
+	buf := make([]byte, 4)
+	for {
+		_, err := rc.Read(buf)
+		if err != nil {
+			break
+		}
+	}
+	rc.Close()
+	if len(ps) != 5 {
+		t.Fatalf("invalid reports: %v", ps)
+	}
+	p := ps[1]
+	if p.Current != 8 || p.Total != 10 || p.Elapsed != 2*time.Second || p.Done {
+		t.Fatalf("invalid progress: %+v", p)
+	}
+	if p.Rate() != 4 {
+		t.Fatalf("invalid rate: %v", p.Rate())
+	}
+	if eta, ok := p.ETA(); !ok || eta != 500*time.Millisecond {
+		t.Fatalf("invalid ETA: %v", eta)
+	}
+	if p := ps[len(ps)-1]; !p.Done || p.Current != 10 {
+		t.Fatalf("invalid progress: %+v", p)
+	}
+}
+
+func TestProgressTrackerInterval(t *testing.T) {
+	clock := ose.NewFakeClock(epoch, time.Second)
+	n := 0
+	tr := ose.NewProgressTracker("foo", -1, clock, ose.ProgressFunc(func(p ose.Progress) { n++ }))
+	tr.Interval = 3 * time.Second
+	for i := 0; i < 6; i++ {
+		tr.Add(1)
+	}
+	tr.Finish()
+	tr.Finish()
+	if n != 3 {
+		t.Fatalf("invalid number of reports: %d", n)
+	}
+}
+
+func TestRenderProgress(t *testing.T) {
+	p := ose.Progress{Name: "foo", Current: 1536, Total: 3072, Elapsed: time.Second}
+	expected := "foo [==========>         ]  50% 1.5KiB/3.0KiB 1.5KiB/s ETA 1s"
+	if s := ose.RenderProgress(p, len(expected)); s != expected {
+		t.Fatalf("invalid rendering: %q", s)
+	}
+	p = ose.Progress{Name: "-", Current: 100, Total: -1, Elapsed: 2 * time.Second, Done: true}
+	if s := ose.RenderProgress(p, 20); s != "- 100B 50B/s 2s     " {
+		t.Fatalf("invalid rendering: %q", s)
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	w := ose.NewFakeWorld()
+	bar := ose.NewProgressBarInWorld(w)
+	bar.ReportProgress(ose.Progress{Name: "foo", Current: 1, Total: 2, Elapsed: time.Second})
+	if s := w.FakeIO.ErrString(); s != "" {
+		t.Fatalf("must not render without a terminal: %q", s)
+	}
+	w.FakeIO.Terminal = true
+	w.FakeIO.Width = 40
+	bar.ReportProgress(ose.Progress{Name: "foo", Current: 1, Total: 2, Elapsed: time.Second})
+	bar.ReportProgress(ose.Progress{Name: "foo", Current: 2, Total: 2, Elapsed: time.Second, Done: true})
+	lines := strings.Split(w.FakeIO.ErrString(), "\r")
+	if len(lines) != 3 || len(lines[1]) != 39 || !strings.HasSuffix(lines[2], "\n") {
+		t.Fatalf("invalid rendering: %q", lines)
+	}
+}
+
+func TestOpenerProgress(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "foo", []byte("hello"), 0644)
+	o := ose.NewOpenerInWorld(w)
+	var last ose.Progress
+	o.Progress = ose.ProgressFunc(func(p ose.Progress) { last = p })
+	readAll(t, o, "foo")
+	if last.Name != "foo" || last.Current != 5 || last.Total != 5 || !last.Done {
+		t.Fatalf("invalid progress: %+v", last)
+	}
+	wc, err := o.Create("-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wc.Write([]byte("abc"))
+	wc.Close()
+	if last.Name != "-" || last.Current != 3 || last.Total != -1 || !last.Done {
+		t.Fatalf("invalid progress: %+v", last)
+	}
+}
+
+func TestCopyTreeProgress(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	fs := afero.NewOsFs()
+	src := filepath.Join(tmp, "src")
+	fs.MkdirAll(filepath.Join(src, "sub"), 0755)
+	afero.WriteFile(fs, filepath.Join(src, "a"), []byte("aaa"), 0644)
+	afero.WriteFile(fs, filepath.Join(src, "sub", "b"), []byte("bbbbb"), 0644)
+	ps := make([]ose.TreeProgress, 0)
+	err = ose.CopyTree(fs, src, filepath.Join(tmp, "dst"), &ose.CopyTreeOptions{
+		Progress: func(p ose.TreeProgress) { ps = append(ps, p) },
+		Clock:    ose.NewFakeClock(epoch, time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := ps[len(ps)-1]
+	if last.Files != 2 || last.TotalFiles != 2 || last.Total.Current != 8 || last.Total.Total != 8 || !last.Total.Done {
+		t.Fatalf("invalid progress: %+v", last)
+	}
+	if last.File.Name != filepath.Join(src, "sub", "b") || last.File.Total != 5 || !last.File.Done {
+		t.Fatalf("invalid progress: %+v", last.File)
+	}
+	if first := ps[0]; first.Total.Done || first.Total.Total != 8 {
+		t.Fatalf("invalid progress: %+v", first)
+	}
+}
+
+type syncFailingFs struct {
+	afero.Fs
+}
+
+type syncFailingFile struct {
+	afero.File
+}
+
+func (fs syncFailingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	f, err := fs.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return syncFailingFile{f}, nil
+}
+
+func (syncFailingFile) Sync() error { return errors.New("no space left on device") }
+
+func TestCopyFileProgressFailure(t *testing.T) {
+	fs := syncFailingFs{afero.NewMemMapFs()}
+	afero.WriteFile(fs, "foo", []byte("hello"), 0644)
+	ps := make([]ose.Progress, 0)
+	err := ose.CopyFile(fs, "foo", "bar", &ose.CopyOptions{
+		Progress: ose.ProgressFunc(func(p ose.Progress) { ps = append(ps, p) }),
+		Clock:    ose.NewFakeClock(epoch, time.Second),
+	})
+	if err == nil {
+		t.Fatal("must fail")
+	}
+	for _, p := range ps {
+		if p.Done {
+			t.Fatalf("must not be done: %+v", p)
+		}
+	}
+}
+
+func TestProgressTrackerDefaultInterval(t *testing.T) {
+	clock := ose.NewFakeClock(epoch, 0)
+	n := 0
+	tr := ose.NewProgressTracker("foo", -1, clock, ose.ProgressFunc(func(p ose.Progress) { n++ }))
+	for i := 0; i < 10; i++ {
+		tr.Add(1)
+	}
+	if n != 0 {
+		t.Fatalf("invalid number of reports: %d", n)
+	}
+	clock.Advance(ose.DefaultProgressInterval)
+	tr.Add(1)
+	if n != 1 {
+		t.Fatalf("invalid number of reports: %d", n)
+	}
+}
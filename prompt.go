@@ -0,0 +1,219 @@
+package ose
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var ErrNotInteractive = errors.New("input is not interactive")
+
+// PasswordReader is an IO which can read a line from In without echoing it.
+type PasswordReader interface {
+	ReadPassword() ([]byte, error)
+}
+
+func (i *realIO) ReadPassword() ([]byte, error) {
+	return terminal.ReadPassword(int(os.Stdin.Fd()))
+}
+
+// Prompter asks questions on Err and reads answers from In of an IO.
+// Every method fails with ErrNotInteractive unless In is a terminal.
+type Prompter struct {
+	io     IO
+	reader *bufio.Reader
+}
+
+func NewPrompter(io IO) *Prompter {
+	return &Prompter{io: io, reader: bufio.NewReader(io.In())}
+}
+
+func NewPrompterInWorld(w World) *Prompter {
+	return NewPrompter(w.IO())
+}
+
+func NewPrompterInThisWorld() *Prompter {
+	return NewPrompterInWorld(GetWorld())
+}
+
+func NewPrompterWithContext(ctx context.Context) *Prompter {
+	return NewPrompterInWorld(WorldFrom(ctx))
+}
+
+func (p *Prompter) interactive() error {
+	if !IsTerminal(p.io, StreamIn) {
+		return ErrNotInteractive
+	}
+	return nil
+}
+
+func (p *Prompter) printf(format string, a ...interface{}) {
+	fmt.Fprintf(p.io.Err(), format, a...)
+}
+
+func (p *Prompter) readLine() (string, error) {
+	line, err := p.reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Confirm asks a yes/no question. An empty answer means def.
+func (p *Prompter) Confirm(message string, def bool) (bool, error) {
+	if err := p.interactive(); err != nil {
+		return def, err
+	}
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		p.printf("%s [%s]: ", message, hint)
+		line, err := p.readLine()
+		if err != nil {
+			return def, err
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		p.printf("Please answer yes or no.\n")
+	}
+}
+
+// Input asks for a free text. An empty answer means def.
+func (p *Prompter) Input(message string, def string) (string, error) {
+	if err := p.interactive(); err != nil {
+		return def, err
+	}
+	if def != "" {
+		p.printf("%s [%s]: ", message, def)
+	} else {
+		p.printf("%s: ", message)
+	}
+	line, err := p.readLine()
+	if err != nil {
+		return def, err
+	}
+	if line == "" {
+		return def, nil
+	}
+	return line, nil
+}
+
+// Password asks for a secret. The answer is not echoed if the IO is a PasswordReader.
+// If the answer was already typed ahead and buffered by the Prompter, it is read from the buffer instead.
+func (p *Prompter) Password(message string) (string, error) {
+	if err := p.interactive(); err != nil {
+		return "", err
+	}
+	p.printf("%s: ", message)
+	if pr, ok := p.io.(PasswordReader); ok && p.reader.Buffered() == 0 {
+		bs, err := pr.ReadPassword()
+		p.printf("\n")
+		return string(bs), err
+	}
+	return p.readLine()
+}
+
+func (p *Prompter) printChoices(choices []string) {
+	for i, choice := range choices {
+		p.printf("  %d) %s\n", i+1, choice)
+	}
+}
+
+func parseChoice(s string, choices []string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if 1 <= n && n <= len(choices) {
+			return n - 1, true
+		}
+		return 0, false
+	}
+	for i, choice := range choices {
+		if s == choice {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Select asks to choose one of choices by its number or name, and returns its index.
+// An empty answer means def, and a negative def means that an answer is required.
+func (p *Prompter) Select(message string, choices []string, def int) (int, error) {
+	if err := p.interactive(); err != nil {
+		return def, err
+	}
+	p.printChoices(choices)
+	for {
+		if 0 <= def && def < len(choices) {
+			p.printf("%s [%d]: ", message, def+1)
+		} else {
+			p.printf("%s: ", message)
+		}
+		line, err := p.readLine()
+		if err != nil {
+			return def, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" && 0 <= def && def < len(choices) {
+			return def, nil
+		}
+		if i, ok := parseChoice(line, choices); ok {
+			return i, nil
+		}
+		p.printf("Please choose from 1 to %d.\n", len(choices))
+	}
+}
+
+// MultiSelect asks to choose any of choices separated by commas or spaces, and returns their indices.
+// An empty answer means defs.
+func (p *Prompter) MultiSelect(message string, choices []string, defs []int) ([]int, error) {
+	if err := p.interactive(); err != nil {
+		return defs, err
+	}
+	p.printChoices(choices)
+	for {
+		hints := make([]string, 0, len(defs))
+		for _, i := range defs {
+			hints = append(hints, strconv.Itoa(i+1))
+		}
+		p.printf("%s [%s]: ", message, strings.Join(hints, ","))
+		line, err := p.readLine()
+		if err != nil {
+			return defs, err
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		if len(fields) == 0 {
+			return defs, nil
+		}
+		results := make([]int, 0, len(fields))
+		valid := true
+		for _, field := range fields {
+			i, ok := parseChoice(field, choices)
+			if !ok {
+				valid = false
+				break
+			}
+			results = append(results, i)
+		}
+		if valid {
+			return results, nil
+		}
+		p.printf("Please choose from 1 to %d.\n", len(choices))
+	}
+}
@@ -0,0 +1,162 @@
+package ose_test
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/taskie/ose"
+)
+
+func newPromptWorld(input string) *ose.FakeWorld {
+	w := ose.NewFakeWorld()
+	w.FakeIO.Terminal = true
+	w.FakeIO.WriteInString(input)
+	return w
+}
+
+func TestPrompterConfirm(t *testing.T) {
+	w := newPromptWorld("maybe\ny\n\n")
+	p := ose.NewPrompterInWorld(w)
+	ok, err := p.Confirm("Overwrite foo?", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("must be yes")
+	}
+	ok, err = p.Confirm("Overwrite bar?", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("must be the default")
+	}
+	expected := "Overwrite foo? [y/N]: Please answer yes or no.\nOverwrite foo? [y/N]: Overwrite bar? [y/N]: "
+	if s := w.FakeIO.ErrString(); s != expected {
+		t.Fatalf("invalid prompt: %q", s)
+	}
+	if s := w.FakeIO.OutString(); s != "" {
+		t.Fatalf("must not write to stdout: %q", s)
+	}
+	_, err = p.Confirm("Again?", false)
+	if err != io.EOF {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
+
+func TestPrompterInput(t *testing.T) {
+	w := newPromptWorld("\nsecret\r\nhello\n")
+	p := ose.NewPrompterInWorld(w)
+	s, err := p.Input("Enter name", "anonymous")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "anonymous" {
+		t.Fatalf("invalid input: %s", s)
+	}
+	s, err = p.Password("Enter token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "secret" {
+		t.Fatalf("invalid password: %s", s)
+	}
+	s, err = p.Input("Enter greeting", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "hello" {
+		t.Fatalf("invalid input: %s", s)
+	}
+}
+
+// passwordIO reads a password from In directly, as the real terminal does.
+type passwordIO struct {
+	*ose.BufIOContainer
+}
+
+func (i passwordIO) ReadPassword() ([]byte, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		_, err := i.In().Read(b)
+		if err != nil {
+			return line, err
+		}
+		if b[0] == '\n' {
+			return line, nil
+		}
+		line = append(line, b[0])
+	}
+}
+
+func TestPrompterPasswordAfterInput(t *testing.T) {
+	w := newPromptWorld("foo\nsecret\n")
+	p := ose.NewPrompter(passwordIO{w.FakeIO})
+	s, err := p.Input("Enter name", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "foo" {
+		t.Fatalf("invalid input: %s", s)
+	}
+	s, err = p.Password("Enter token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "secret" {
+		t.Fatalf("invalid password: %s", s)
+	}
+
+	w = newPromptWorld("")
+	p = ose.NewPrompter(passwordIO{w.FakeIO})
+	w.FakeIO.WriteInString("secret\n")
+	s, err = p.Password("Enter token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "secret" {
+		t.Fatalf("invalid password: %s", s)
+	}
+}
+
+func TestPrompterSelect(t *testing.T) {
+	w := newPromptWorld("4\nbar\n\n1, baz\n")
+	p := ose.NewPrompterInWorld(w)
+	choices := []string{"foo", "bar", "baz"}
+	i, err := p.Select("Choose", choices, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != 1 {
+		t.Fatalf("invalid choice: %d", i)
+	}
+	is, err := p.MultiSelect("Choose", choices, []int{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(is, []int{2}) {
+		t.Fatalf("invalid choices: %v", is)
+	}
+	is, err = p.MultiSelect("Choose", choices, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(is, []int{0, 2}) {
+		t.Fatalf("invalid choices: %v", is)
+	}
+}
+
+func TestPrompterNotInteractive(t *testing.T) {
+	w := ose.NewFakeWorld()
+	w.FakeIO.WriteInString("y\n")
+	p := ose.NewPrompterInWorld(w)
+	ok, err := p.Confirm("Overwrite foo?", false)
+	if err != ose.ErrNotInteractive {
+		t.Fatalf("invalid error: %v", err)
+	}
+	if ok {
+		t.Fatal("must be the default")
+	}
+}
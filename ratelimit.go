@@ -0,0 +1,122 @@
+package ose
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket of bytes, which sleeps on a Clock when the tokens run out.
+// It is safe for concurrent use, so streams sharing a RateLimiter share the bandwidth.
+type RateLimiter struct {
+	// BytesPerSecond is the rate of refilling tokens. It must be positive.
+	BytesPerSecond int64
+	// Burst is the capacity of the bucket, and the largest chunk transferred at once.
+	Burst  int64
+	clock  Clock
+	mutex  sync.Mutex
+	tokens int64
+	last   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter whose bucket holds tokens for one second. A nil clock means the real clock.
+// It panics if bytesPerSecond is not positive, like time.NewTicker. Use a nil RateLimiter for no limit.
+func NewRateLimiter(bytesPerSecond int64, clock Clock) *RateLimiter {
+	if bytesPerSecond <= 0 {
+		panic("ose: non-positive rate for NewRateLimiter")
+	}
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &RateLimiter{
+		BytesPerSecond: bytesPerSecond,
+		Burst:          bytesPerSecond,
+		clock:          clock,
+		tokens:         bytesPerSecond,
+		last:           clock.Now(),
+	}
+}
+
+func (l *RateLimiter) chunk(n int) int {
+	if l.Burst > 0 && int64(n) > l.Burst {
+		return int(l.Burst)
+	}
+	return n
+}
+
+// Wait takes n tokens, and sleeps until the tokens are refilled if they are short.
+func (l *RateLimiter) Wait(n int) {
+	l.mutex.Lock()
+	now := l.clock.Now()
+	elapsed := now.Sub(l.last)
+	l.last = now
+	l.tokens += int64(elapsed.Seconds() * float64(l.BytesPerSecond))
+	if l.tokens > l.Burst {
+		l.tokens = l.Burst
+	}
+	l.tokens -= int64(n)
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(float64(-l.tokens) / float64(l.BytesPerSecond) * float64(time.Second))
+		// the sleeping time is paid in advance
+		l.tokens = 0
+		l.last = now.Add(d)
+	}
+	l.mutex.Unlock()
+	if d > 0 {
+		l.clock.Sleep(d)
+	}
+}
+
+// RateLimitedReadCloser implements io.ReadCloser.
+// It reads at most Burst bytes at once from the underlying ReadCloser and waits for the RateLimiter after reading.
+type RateLimitedReadCloser struct {
+	ReadCloser io.ReadCloser
+	Limiter    *RateLimiter
+}
+
+func NewRateLimitedReadCloser(rc io.ReadCloser, l *RateLimiter) *RateLimitedReadCloser {
+	return &RateLimitedReadCloser{ReadCloser: rc, Limiter: l}
+}
+
+func (rrc *RateLimitedReadCloser) Read(p []byte) (int, error) {
+	n, err := rrc.ReadCloser.Read(p[:rrc.Limiter.chunk(len(p))])
+	if n > 0 {
+		rrc.Limiter.Wait(n)
+	}
+	return n, err
+}
+
+func (rrc *RateLimitedReadCloser) Close() error {
+	return rrc.ReadCloser.Close()
+}
+
+// RateLimitedWriteCloser implements io.WriteCloser.
+// It writes chunks of at most Burst bytes to the underlying WriteCloser, waiting for the RateLimiter before each chunk.
+type RateLimitedWriteCloser struct {
+	WriteCloser io.WriteCloser
+	Limiter     *RateLimiter
+}
+
+func NewRateLimitedWriteCloser(wc io.WriteCloser, l *RateLimiter) *RateLimitedWriteCloser {
+	return &RateLimitedWriteCloser{WriteCloser: wc, Limiter: l}
+}
+
+func (rwc *RateLimitedWriteCloser) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		chunk := p[written:]
+		chunk = chunk[:rwc.Limiter.chunk(len(chunk))]
+		rwc.Limiter.Wait(len(chunk))
+		n, err := rwc.WriteCloser.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (rwc *RateLimitedWriteCloser) Close() error {
+	return rwc.WriteCloser.Close()
+}
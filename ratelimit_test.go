@@ -0,0 +1,97 @@
+package ose_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/taskie/ose"
+)
+
+func TestRateLimitedWriteCloser(t *testing.T) {
+	clock := ose.NewFakeClock(epoch, 0)
+	buf := new(bytes.Buffer)
+	wc := ose.NewRateLimitedWriteCloser(ose.NopWriteCloser(buf), ose.NewRateLimiter(10, clock))
+	done := make(chan error)
+	go func() {
+		_, err := wc.Write(bytes.Repeat([]byte("x"), 25))
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	if buf.Len() != 10 {
+		t.Fatalf("invalid length: %d", buf.Len())
+	}
+	clock.Advance(time.Second)
+	clock.BlockUntil(1)
+	if buf.Len() != 20 {
+		t.Fatalf("invalid length: %d", buf.Len())
+	}
+	clock.Advance(500 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 25 {
+		t.Fatalf("invalid length: %d", buf.Len())
+	}
+}
+
+func TestRateLimitedReadCloser(t *testing.T) {
+	clock := ose.NewFakeClock(epoch, 0)
+	rc := ose.NewRateLimitedReadCloser(ose.NopReadCloser(bytes.NewReader(make([]byte, 30))), ose.NewRateLimiter(10, clock))
+	buf := make([]byte, 100)
+	n, err := rc.Read(buf)
+	if err != nil || n != 10 {
+		t.Fatalf("invalid read: %d, %v", n, err)
+	}
+	done := make(chan int)
+	go func() {
+		n, _ := rc.Read(buf)
+		done <- n
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	if n := <-done; n != 10 {
+		t.Fatalf("invalid read: %d", n)
+	}
+}
+
+func TestNewRateLimiterNonPositive(t *testing.T) {
+	for _, rate := range []int64{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("must panic: %d", rate)
+				}
+			}()
+			ose.NewRateLimiter(rate, nil)
+		}()
+	}
+}
+
+func TestOpenerRateLimiter(t *testing.T) {
+	w := ose.NewFakeWorld()
+	clock := ose.NewFakeClock(epoch, 0)
+	o := ose.NewOpenerInWorld(w)
+	o.RateLimiter = ose.NewRateLimiter(4, clock)
+	wc, err := o.Create("-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := io.WriteString(wc, "foobar")
+		if err == nil {
+			err = wc.Close()
+		}
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(500 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if s := w.FakeIO.OutString(); s != "foobar" {
+		t.Fatalf("invalid output: %q", s)
+	}
+}
@@ -0,0 +1,157 @@
+package ose
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Stream int
+
+const (
+	StreamIn Stream = iota
+	StreamOut
+	StreamErr
+)
+
+func (s Stream) String() string {
+	switch s {
+	case StreamIn:
+		return "stdin"
+	case StreamOut:
+		return "stdout"
+	case StreamErr:
+		return "stderr"
+	}
+	return "unknown"
+}
+
+// RecordedWrite is a write to stdout or stderr captured by RecordingIO.
+type RecordedWrite struct {
+	Stream Stream
+	Time   time.Time
+	Data   []byte
+}
+
+// RecordingIO is an IO which records every write to Out and Err in order.
+type RecordingIO struct {
+	in      io.Reader
+	clock   Clock
+	records []RecordedWrite
+	mutex   sync.Mutex
+}
+
+// NewRecordingIO creates a RecordingIO which reads from in and stamps writes with clock.
+func NewRecordingIO(in io.Reader, clock Clock) *RecordingIO {
+	return &RecordingIO{in: in, clock: clock}
+}
+
+func NewRecordingIOInWorld(w World) *RecordingIO {
+	return NewRecordingIO(w.IO().In(), w.Clock())
+}
+
+func (i *RecordingIO) In() io.Reader  { return i.in }
+func (i *RecordingIO) Out() io.Writer { return &recordingWriter{io: i, stream: StreamOut} }
+func (i *RecordingIO) Err() io.Writer { return &recordingWriter{io: i, stream: StreamErr} }
+
+type recordingWriter struct {
+	io     *RecordingIO
+	stream Stream
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	i := w.io
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.records = append(i.records, RecordedWrite{
+		Stream: w.stream,
+		Time:   i.clock.Now(),
+		Data:   append([]byte{}, p...),
+	})
+	return len(p), nil
+}
+
+// Transcript returns a copy of the recorded writes.
+func (i *RecordingIO) Transcript() []RecordedWrite {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return append([]RecordedWrite{}, i.records...)
+}
+
+func (i *RecordingIO) content(pred func(s Stream) bool) string {
+	buf := new(bytes.Buffer)
+	for _, r := range i.Transcript() {
+		if pred(r.Stream) {
+			buf.Write(r.Data)
+		}
+	}
+	return buf.String()
+}
+
+func (i *RecordingIO) Stdout() string {
+	return i.content(func(s Stream) bool { return s == StreamOut })
+}
+
+func (i *RecordingIO) Stderr() string {
+	return i.content(func(s Stream) bool { return s == StreamErr })
+}
+
+// Combined returns stdout and stderr interleaved as a terminal shows them.
+func (i *RecordingIO) Combined() string {
+	return i.content(func(s Stream) bool { return true })
+}
+
+// Render formats the transcript line by line, e.g. "out| hello" or "err| oops".
+// A line not terminated by a newline is marked with "%" instead of "|".
+// If withTime is true, each line is prefixed with the time when it started to be written.
+func (i *RecordingIO) Render(withTime bool) string {
+	sb := new(strings.Builder)
+	var line []byte
+	var lineStream Stream
+	var lineTime time.Time
+	emit := func(mark string) {
+		if withTime {
+			sb.WriteString(lineTime.UTC().Format(time.RFC3339Nano))
+			sb.WriteString(" ")
+		}
+		if lineStream == StreamErr {
+			sb.WriteString("err")
+		} else {
+			sb.WriteString("out")
+		}
+		sb.WriteString(mark)
+		if len(line) != 0 {
+			sb.WriteString(" ")
+			sb.Write(line)
+		}
+		sb.WriteString("\n")
+		line = nil
+	}
+	for _, r := range i.Transcript() {
+		if line != nil && r.Stream != lineStream {
+			emit("%")
+		}
+		data := r.Data
+		for len(data) != 0 {
+			if line == nil {
+				line = []byte{}
+				lineStream = r.Stream
+				lineTime = r.Time
+			}
+			n := bytes.IndexByte(data, '\n')
+			if n < 0 {
+				line = append(line, data...)
+				break
+			}
+			line = append(line, data[:n]...)
+			emit("|")
+			data = data[n+1:]
+		}
+	}
+	if line != nil {
+		emit("%")
+	}
+	return sb.String()
+}
@@ -0,0 +1,54 @@
+package ose_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taskie/ose"
+)
+
+func TestRecordingIO(t *testing.T) {
+	rio := ose.NewRecordingIO(strings.NewReader(""), ose.NewFakeClock(epoch, time.Second))
+	fmt.Fprint(rio.Out(), "hello, ")
+	fmt.Fprint(rio.Out(), "world\nfoo")
+	fmt.Fprint(rio.Err(), "warning\n")
+	fmt.Fprint(rio.Out(), "bar\n\n")
+	fmt.Fprint(rio.Err(), "done")
+
+	if s := rio.Stdout(); s != "hello, world\nfoobar\n\n" {
+		t.Fatalf("invalid stdout: %q", s)
+	}
+	if s := rio.Stderr(); s != "warning\ndone" {
+		t.Fatalf("invalid stderr: %q", s)
+	}
+	if s := rio.Combined(); s != "hello, world\nfoowarning\nbar\n\ndone" {
+		t.Fatalf("invalid combined output: %q", s)
+	}
+	if n := len(rio.Transcript()); n != 5 {
+		t.Fatalf("invalid transcript length: %d", n)
+	}
+
+	expected := `out| hello, world
+out% foo
+err| warning
+out| bar
+out|
+err% done
+`
+	if s := rio.Render(false); s != expected {
+		t.Fatalf("invalid rendering:\n%s", s)
+	}
+
+	expected = `2020-01-01T00:00:00Z out| hello, world
+2020-01-01T00:00:01Z out% foo
+2020-01-01T00:00:02Z err| warning
+2020-01-01T00:00:03Z out| bar
+2020-01-01T00:00:03Z out|
+2020-01-01T00:00:04Z err% done
+`
+	if s := rio.Render(true); s != expected {
+		t.Fatalf("invalid rendering:\n%s", s)
+	}
+}
@@ -0,0 +1,97 @@
+package ose
+
+import (
+	"io"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// RewriteOptions configures Opener.RewriteWithOptions.
+type RewriteOptions struct {
+	// BackupSuffix keeps the original file as name+BackupSuffix (e.g. ".bak") if not empty.
+	// Otherwise Backup of the Opener is used. NoClobber and Append of the Opener are ignored.
+	BackupSuffix string
+}
+
+// Rewrite rewrites a file in place like sed -i. See RewriteWithOptions.
+func (o *Opener) Rewrite(name string, handler func(r io.Reader, w io.Writer) (bool, error)) (bool, error) {
+	return o.RewriteWithOptions(name, nil, handler)
+}
+
+// RewriteWithOptions calls handler with the file and a temporary file in the same directory,
+// and replaces the file with the temporary one only if handler returns true without errors.
+// The mode and the owner (on OsFs) of the file are preserved.
+// If name is "-", handler reads stdin and writes stdout instead.
+func (o *Opener) RewriteWithOptions(name string, opts *RewriteOptions, handler func(r io.Reader, w io.Writer) (bool, error)) (bool, error) {
+	if opts == nil {
+		opts = &RewriteOptions{}
+	}
+	if o.shouldFallback(name) {
+		return o.rewriteStdio(handler)
+	}
+	name, err := o.resolveFilePath("rewrite", name)
+	if err != nil {
+		return false, err
+	}
+	fi, err := o.fs.Stat(name)
+	if err != nil {
+		return false, err
+	}
+	rc, err := o.Open(name)
+	if err != nil {
+		return false, err
+	}
+	closed := false
+	closeSource := func() error {
+		if closed {
+			return nil
+		}
+		closed = true
+		return rc.Close()
+	}
+	defer closeSource()
+	o2 := *o
+	o2.NoClobber = false
+	o2.Append = false
+	if opts.BackupSuffix != "" {
+		o2.Backup = BackupNone
+	}
+	return o2.createTempFile(filepath.Dir(name), "."+filepath.Base(name)+".", name, "", func(w io.WriteCloser) (bool, error) {
+		ok, err := handler(rc, w)
+		// the file can't be replaced while it is open on Windows
+		err2 := closeSource()
+		if err != nil {
+			return ok, err
+		}
+		return ok, err2
+	}, func(f afero.File) error {
+		err := o.fs.Chmod(f.Name(), fi.Mode())
+		if err != nil {
+			return err
+		}
+		preserveOwner(o.fs, fi, f.Name())
+		if opts.BackupSuffix != "" {
+			return CopyFile(o.fs, name, name+opts.BackupSuffix, nil)
+		}
+		return nil
+	})
+}
+
+func (o *Opener) rewriteStdio(handler func(r io.Reader, w io.Writer) (bool, error)) (bool, error) {
+	rc, err := o.Open("-")
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+	wc, err := o.Create("-")
+	if err != nil {
+		return false, err
+	}
+	ok, err := handler(rc, wc)
+	err2 := wc.Close()
+	if err != nil {
+		return ok, err
+	}
+	return ok, err2
+}
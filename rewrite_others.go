@@ -0,0 +1,13 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package ose
+
+import (
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+// preserveOwner does nothing on platforms without the owners of files.
+func preserveOwner(fs afero.Fs, fi os.FileInfo, name string) {}
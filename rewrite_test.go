@@ -0,0 +1,194 @@
+package ose_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+func upper(r io.Reader, w io.Writer) (bool, error) {
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return false, err
+	}
+	_, err = io.WriteString(w, strings.ToUpper(string(bs)))
+	return true, err
+}
+
+func TestOpenerRewrite(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "dir/foo.txt", []byte("foo"), 0600)
+	o := ose.NewOpenerInWorld(w)
+	ok, err := o.RewriteWithOptions("dir/foo.txt", &ose.RewriteOptions{BackupSuffix: ".bak"}, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("must be ok")
+	}
+	bs, _ := afero.ReadFile(w.FakeFs, "dir/foo.txt")
+	if string(bs) != "FOO" {
+		t.Fatalf("invalid content: %q", bs)
+	}
+	fi, _ := w.FakeFs.Stat("dir/foo.txt")
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("invalid mode: %v", fi.Mode())
+	}
+	bs, _ = afero.ReadFile(w.FakeFs, "dir/foo.txt.bak")
+	if string(bs) != "foo" {
+		t.Fatalf("invalid backup: %q", bs)
+	}
+	entries, _ := afero.ReadDir(w.FakeFs, "dir")
+	if len(entries) != 2 {
+		t.Fatalf("temporary files must be removed: %v", entries)
+	}
+}
+
+func TestOpenerRewriteCancel(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "foo.txt", []byte("foo"), 0644)
+	o := ose.NewOpenerInWorld(w)
+	ok, err := o.RewriteWithOptions("foo.txt", &ose.RewriteOptions{BackupSuffix: ".bak"}, func(r io.Reader, w io.Writer) (bool, error) {
+		io.WriteString(w, "bar")
+		return false, nil
+	})
+	if err != nil || ok {
+		t.Fatalf("must be cancelled: %v", err)
+	}
+	e := errors.New("failed")
+	_, err = o.Rewrite("foo.txt", func(r io.Reader, w io.Writer) (bool, error) {
+		io.WriteString(w, "bar")
+		return true, e
+	})
+	if err != e {
+		t.Fatalf("invalid error: %v", err)
+	}
+	bs, _ := afero.ReadFile(w.FakeFs, "foo.txt")
+	if string(bs) != "foo" {
+		t.Fatalf("must not be rewritten: %q", bs)
+	}
+	if ose.Exists(w.FakeFs, "foo.txt.bak") {
+		t.Fatal("must not be backed up")
+	}
+	entries, _ := afero.ReadDir(w.FakeFs, ".")
+	if len(entries) != 1 {
+		t.Fatalf("temporary files must be removed: %v", entries)
+	}
+	_, err = o.Rewrite("missing.txt", upper)
+	if !os.IsNotExist(err) {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
+
+func TestOpenerRewriteStdio(t *testing.T) {
+	w := ose.NewFakeWorld()
+	w.FakeIO.WriteInString("foo")
+	o := ose.NewOpenerInWorld(w)
+	ok, err := o.Rewrite("-", upper)
+	if err != nil || !ok {
+		t.Fatalf("must be ok: %v", err)
+	}
+	if s := w.FakeIO.OutString(); s != "FOO" {
+		t.Fatalf("invalid output: %q", s)
+	}
+}
+
+func TestOpenerRewriteOsFs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "run.sh")
+	err = ioutil.WriteFile(name, []byte("echo foo\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := ose.NewOpener(afero.NewOsFs(), ose.NewBufIOContainer())
+	o.AutoCompression = true
+	_, err = o.Rewrite(name, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0755 {
+		t.Fatalf("invalid mode: %v", fi.Mode())
+	}
+	bs, _ := ioutil.ReadFile(name)
+	if string(bs) != "ECHO FOO\n" {
+		t.Fatalf("invalid content: %q", bs)
+	}
+}
+
+func TestOpenerRewriteBackupCommitFailure(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "foo.txt", []byte("foo"), 0644)
+	o := ose.NewOpener(&moveFailingFs{Fs: w.FakeFs, target: "foo.txt"}, w.IO())
+	_, err := o.RewriteWithOptions("foo.txt", &ose.RewriteOptions{BackupSuffix: ".bak"}, upper)
+	if err == nil {
+		t.Fatal("must fail")
+	}
+	assertFile(t, w.FakeFs, "foo.txt", "foo")
+	assertFile(t, w.FakeFs, "foo.txt.bak", "foo")
+}
+
+// windowsLikeFs fails to replace open files like Windows.
+type windowsLikeFs struct {
+	afero.Fs
+	open map[string]int
+}
+
+type windowsLikeFile struct {
+	afero.File
+	fs   *windowsLikeFs
+	name string
+}
+
+func (fs *windowsLikeFs) Open(name string) (afero.File, error) {
+	return fs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (fs *windowsLikeFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&os.O_TRUNC != 0 && fs.open[name] > 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("sharing violation")}
+	}
+	f, err := fs.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	fs.open[name]++
+	return &windowsLikeFile{File: f, fs: fs, name: name}, nil
+}
+
+func (fs *windowsLikeFs) Rename(oldname, newname string) error {
+	if fs.open[newname] > 0 {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: errors.New("access is denied")}
+	}
+	return fs.Fs.Rename(oldname, newname)
+}
+
+func (f *windowsLikeFile) Close() error {
+	f.fs.open[f.name]--
+	return f.File.Close()
+}
+
+func TestOpenerRewriteClosesSource(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "foo.txt", []byte("foo"), 0644)
+	o := ose.NewOpener(&windowsLikeFs{Fs: w.FakeFs, open: make(map[string]int)}, w.IO())
+	_, err := o.Rewrite("foo.txt", upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, w.FakeFs, "foo.txt", "FOO")
+}
@@ -0,0 +1,21 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package ose
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/spf13/afero"
+)
+
+// preserveOwner changes the owner of name to the one of fi if possible. Failures are ignored like cp -p.
+func preserveOwner(fs afero.Fs, fi os.FileInfo, name string) {
+	if _, ok := fs.(*afero.OsFs); !ok {
+		return
+	}
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		_ = os.Lchown(name, int(stat.Uid), int(stat.Gid))
+	}
+}
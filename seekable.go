@@ -0,0 +1,77 @@
+package ose
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/spf13/afero"
+)
+
+// DefaultSpoolThreshold is the default of Opener.SpoolThreshold.
+const DefaultSpoolThreshold = 1 << 20
+
+// OpenSeekable opens a file like Open, and returns a ReadSeekCloser even if the input can't be seeked, like stdin, URLs and compressed files.
+// Such an input is read entirely into memory, or a temporary file if it is larger than SpoolThreshold.
+// The temporary file is removed on Close.
+func (o *Opener) OpenSeekable(name string) (ReadSeekCloser, error) {
+	o2 := *o
+	o2.Unbuffered = true
+	rc, err := o2.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if rsc, ok := rc.(ReadSeekCloser); ok {
+		return rsc, nil
+	}
+	rsc, err := o.spool(rc)
+	err2 := rc.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err2 != nil {
+		rsc.Close()
+		return nil, err2
+	}
+	return rsc, nil
+}
+
+// spool reads r into memory up to SpoolThreshold bytes, and the rest into a temporary file.
+func (o *Opener) spool(r io.Reader) (ReadSeekCloser, error) {
+	threshold := o.SpoolThreshold
+	if threshold == 0 {
+		threshold = DefaultSpoolThreshold
+	} else if threshold < 0 {
+		threshold = 0
+	}
+	buf := bytes.Buffer{}
+	_, err := io.CopyN(&buf, r, threshold+1)
+	if err == io.EOF {
+		return NopReadSeekCloser(bytes.NewReader(buf.Bytes())), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f, err := afero.TempFile(o.fs, o.SpoolDir, "ose-spool-")
+	if err != nil {
+		return nil, err
+	}
+	remove := func() error {
+		err := f.Close()
+		err2 := o.fs.Remove(f.Name())
+		if err != nil {
+			return err
+		}
+		return err2
+	}
+	_, err = io.Copy(f, io.MultiReader(&buf, r))
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = remove()
+		return nil, err
+	}
+	return NewReadSeekCloser(f, func(_ io.ReadSeeker) error {
+		return remove()
+	}), nil
+}
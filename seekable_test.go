@@ -0,0 +1,114 @@
+package ose_test
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+func readTwice(t *testing.T, rsc ose.ReadSeekCloser) (string, string) {
+	t.Helper()
+	first, err := ioutil.ReadAll(rsc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rsc.Seek(0, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ioutil.ReadAll(rsc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(first), string(second)
+}
+
+func TestOpenerOpenSeekableStdin(t *testing.T) {
+	w := ose.NewFakeWorld()
+	w.FakeIO.WriteInString("foo\nbar\n")
+	o := ose.NewOpenerInWorld(w)
+	o.SpoolDir = "/tmp"
+	rsc, err := o.OpenSeekable("-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first, second := readTwice(t, rsc); first != "foo\nbar\n" || second != first {
+		t.Fatalf("invalid content: %q, %q", first, second)
+	}
+	if fis, _ := afero.ReadDir(w.FakeFs, "/tmp"); len(fis) != 0 {
+		t.Fatal("must not spool to a file")
+	}
+	err = rsc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenerOpenSeekableSpool(t *testing.T) {
+	w := ose.NewFakeWorld()
+	data := strings.Repeat("0123456789", 100)
+	w.FakeIO.WriteInString(data)
+	o := ose.NewOpenerInWorld(w)
+	o.SpoolThreshold = 64
+	o.SpoolDir = "/tmp"
+	rsc, err := o.OpenSeekable("-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fis, _ := afero.ReadDir(w.FakeFs, "/tmp"); len(fis) != 1 {
+		t.Fatalf("must spool to a file: %v", fis)
+	}
+	if first, second := readTwice(t, rsc); first != data || second != first {
+		t.Fatalf("invalid content: %q, %q", first, second)
+	}
+	err = rsc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fis, _ := afero.ReadDir(w.FakeFs, "/tmp"); len(fis) != 0 {
+		t.Fatalf("must remove the spool: %v", fis)
+	}
+}
+
+func TestOpenerOpenSeekableFile(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "foo.txt", []byte("foo"), 0644)
+	o := ose.NewOpenerInWorld(w)
+	rsc, err := o.OpenSeekable("foo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsc.Close()
+	if _, ok := rsc.(afero.File); !ok {
+		t.Fatalf("must be the file itself: %T", rsc)
+	}
+	if first, second := readTwice(t, rsc); first != "foo" || second != first {
+		t.Fatalf("invalid content: %q, %q", first, second)
+	}
+
+	o.AutoCompression = true
+	wc, _ := o.Create("foo.txt.gz")
+	io.WriteString(wc, "compressed")
+	wc.Close()
+	rsc2, err := o.OpenSeekable("foo.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsc2.Close()
+	if first, second := readTwice(t, rsc2); first != "compressed" || second != first {
+		t.Fatalf("invalid content: %q, %q", first, second)
+	}
+}
+
+func TestOpenerOpenSeekableMissing(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	_, err := o.OpenSeekable("missing.txt")
+	if err == nil {
+		t.Fatal("must fail")
+	}
+}
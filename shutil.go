@@ -20,6 +20,9 @@ func Exists(fs afero.Fs, filePath string) bool {
 
 type CopyOptions struct {
 	NoOverwrite bool
+	// Progress receives the progress of copying if not nil. Clock measures the elapsed time.
+	Progress ProgressSink
+	Clock    Clock
 }
 
 func CopyFile(fs afero.Fs, oldname string, newname string, opts *CopyOptions) error {
@@ -44,12 +47,35 @@ func CopyFile(fs afero.Fs, oldname string, newname string, opts *CopyOptions) er
 		return err
 	}
 	defer newFile.Close()
-	_, err = io.Copy(newFile, oldFile)
+	var r io.Reader = oldFile
+	var tracker *ProgressTracker
+	if opts.Progress != nil {
+		tracker = NewProgressTracker(oldname, oldInfo.Size(), opts.Clock, opts.Progress)
+		r = io.TeeReader(oldFile, progressAdder{tracker})
+	}
+	_, err = io.Copy(newFile, r)
 	if err != nil {
 		return err
 	}
 	err = newFile.Sync()
-	return err
+	if err != nil {
+		return err
+	}
+	// the progress isn't finished on failure
+	if tracker != nil {
+		tracker.Finish()
+	}
+	return nil
+}
+
+// progressAdder counts the bytes written to it by the tracker.
+type progressAdder struct {
+	tracker *ProgressTracker
+}
+
+func (w progressAdder) Write(p []byte) (int, error) {
+	w.tracker.Add(len(p))
+	return len(p), nil
 }
 
 func Copy(fs afero.Fs, oldname string, newname string) error {
@@ -110,6 +136,70 @@ func Touch(fs afero.Fs, path string) error {
 
 type CopyTreeOptions struct {
 	NoOverwrite bool
+	// Progress is called with the progress of each file and of all files if not nil. Clock measures the elapsed time.
+	Progress func(p TreeProgress)
+	Clock    Clock
+}
+
+// TreeProgress is a progress of CopyTree.
+type TreeProgress struct {
+	// File is the progress of the file being copied.
+	File Progress
+	// Total is the progress of all files, whose Total is the sum of their sizes.
+	Total Progress
+	// Files is the number of files copied, and TotalFiles is the number of all files.
+	Files      int
+	TotalFiles int
+}
+
+type treeProgressTracker struct {
+	opts  *CopyTreeOptions
+	clock Clock
+	start time.Time
+	done  int64
+	p     TreeProgress
+}
+
+func newTreeProgressTracker(fs afero.Fs, root string, opts *CopyTreeOptions) (*treeProgressTracker, error) {
+	clock := opts.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	t := &treeProgressTracker{opts: opts, clock: clock, start: clock.Now()}
+	t.p.Total = Progress{Name: root}
+	err := afero.Walk(fs, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			t.p.Total.Total += info.Size()
+			t.p.TotalFiles++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *treeProgressTracker) ReportProgress(p Progress) {
+	t.p.File = p
+	t.p.Total.Current = t.done + p.Current
+	t.p.Total.Elapsed = t.clock.Now().Sub(t.start)
+	if p.Done {
+		t.done += p.Current
+		t.p.Files++
+		t.p.Total.Done = t.p.Files == t.p.TotalFiles
+	}
+	t.opts.Progress(t.p)
+}
+
+func (t *treeProgressTracker) copyOptions() *CopyOptions {
+	if t == nil {
+		return nil
+	}
+	return &CopyOptions{Progress: t, Clock: t.clock}
 }
 
 func CopyTree(fs afero.Fs, oldname, newname string, opts *CopyTreeOptions) error {
@@ -133,12 +223,19 @@ func CopyTree(fs afero.Fs, oldname, newname string, opts *CopyTreeOptions) error
 			return err
 		}
 	}
-	return copyTreeContent(fs, oldname, newname, opts, 1)
+	var tracker *treeProgressTracker
+	if opts.Progress != nil {
+		tracker, err = newTreeProgressTracker(fs, oldname, opts)
+		if err != nil {
+			return err
+		}
+	}
+	return copyTreeContent(fs, oldname, newname, tracker, 1)
 }
 
 // https://stackoverflow.com/questions/51779243/copy-a-folder-in-go
 
-func copyTreeContent(fs afero.Fs, oldname, newname string, opts *CopyTreeOptions, depth int) error {
+func copyTreeContent(fs afero.Fs, oldname, newname string, tracker *treeProgressTracker, depth int) error {
 	if depth > 127 {
 		return fmt.Errorf("max depth exceeded")
 	}
@@ -167,13 +264,13 @@ func copyTreeContent(fs afero.Fs, oldname, newname string, opts *CopyTreeOptions
 					return err
 				}
 			}
-			if err := copyTreeContent(fs, src, dst, opts, depth+1); err != nil {
+			if err := copyTreeContent(fs, src, dst, tracker, depth+1); err != nil {
 				return err
 			}
 		case os.ModeSymlink:
 			return fmt.Errorf("unimplemented: copy symlink")
 		default:
-			if err := Copy(fs, src, dst); err != nil {
+			if err := CopyFile(fs, src, dst, tracker.copyOptions()); err != nil {
 				return err
 			}
 		}
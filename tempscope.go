@@ -1,11 +1,16 @@
 package ose
 
 import (
+	"path/filepath"
+
 	"github.com/spf13/afero"
+	"github.com/taskie/ose/osplus"
 )
 
 type TempScope struct {
-	fs afero.Fs
+	// Durable makes TempFileScope sync the file before renaming it, and the directory after renaming it.
+	Durable bool
+	fs      afero.Fs
 }
 
 func NewTempScope(fs afero.Fs) *TempScope {
@@ -35,13 +40,58 @@ func (s *TempScope) TempFileScopeLazy(dir, prefix string, handler func(f afero.F
 		_ = s.fs.Remove(oldname)
 		return newname, err
 	}
-	err = f.Close()
+	return newname, s.commit(f, newname)
+}
+
+// commit closes the temporary file f and moves it to newname, or removes it on failure.
+func (s *TempScope) commit(f afero.File, newname string) error {
+	err := s.closeTemp(f)
+	if err != nil {
+		return err
+	}
+	return s.moveTemp(f.Name(), newname)
+}
+
+// closeTemp syncs the temporary file f if Durable and closes it, or removes it on failure.
+func (s *TempScope) closeTemp(f afero.File) error {
+	var err error
+	if s.Durable {
+		err = osplus.SyncClose(f)
+	} else {
+		err = f.Close()
+	}
+	if err != nil {
+		_ = s.fs.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// moveTemp moves the closed temporary file to newname, and syncs the directory if Durable.
+// The temporary file is removed if it can't be moved.
+func (s *TempScope) moveTemp(oldname, newname string) error {
+	err := Move(s.fs, oldname, newname)
 	if err != nil {
 		_ = s.fs.Remove(oldname)
-		return newname, err
+		return err
 	}
-	err = Move(s.fs, oldname, newname)
-	return newname, err
+	if !s.Durable {
+		return nil
+	}
+	return SyncDir(s.fs, filepath.Dir(newname))
+}
+
+// SyncDir syncs a directory of fs so that renames and creations in it persist, like osplus.SyncDir.
+// It does nothing if fs can't sync directories.
+func SyncDir(fs afero.Fs, dir string) error {
+	if _, ok := fs.(*afero.OsFs); ok {
+		return osplus.SyncDir(dir)
+	}
+	d, err := fs.Open(dir)
+	if err != nil {
+		return err
+	}
+	return osplus.SyncClose(d)
 }
 
 func (s *TempScope) TempDirScope(dir, prefix, newname string, handler func(tempname string) (bool, error)) (bool, error) {
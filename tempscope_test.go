@@ -2,6 +2,10 @@ package ose_test
 
 import (
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
 	"testing"
 
 	"github.com/spf13/afero"
@@ -58,3 +62,113 @@ func TestTempDirScope(t *testing.T) {
 		t.Fatalf("not directory")
 	}
 }
+
+type syncLog struct {
+	ops     []string
+	dirSync error
+}
+
+type syncRecordingFs struct {
+	afero.Fs
+	log *syncLog
+}
+
+type syncRecordingFile struct {
+	afero.File
+	log *syncLog
+}
+
+func (fs *syncRecordingFs) wrap(f afero.File, err error) (afero.File, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &syncRecordingFile{File: f, log: fs.log}, nil
+}
+
+func (fs *syncRecordingFs) Open(name string) (afero.File, error) {
+	return fs.wrap(fs.Fs.Open(name))
+}
+
+func (fs *syncRecordingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return fs.wrap(fs.Fs.OpenFile(name, flag, perm))
+}
+
+func (fs *syncRecordingFs) Rename(oldname, newname string) error {
+	fs.log.ops = append(fs.log.ops, "rename "+filepath.Base(newname))
+	return fs.Fs.Rename(oldname, newname)
+}
+
+func (f *syncRecordingFile) Sync() error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		f.log.ops = append(f.log.ops, "sync dir "+f.Name())
+		return f.log.dirSync
+	}
+	f.log.ops = append(f.log.ops, "sync file")
+	return f.File.Sync()
+}
+
+func (f *syncRecordingFile) Close() error {
+	fi, err := f.Stat()
+	if err == nil && !fi.IsDir() {
+		f.log.ops = append(f.log.ops, "close file")
+	}
+	return f.File.Close()
+}
+
+func TestTempFileScopeDurable(t *testing.T) {
+	log := &syncLog{}
+	fs := &syncRecordingFs{Fs: afero.NewMemMapFs(), log: log}
+	fs.MkdirAll("/dir", 0755)
+	s := ose.NewTempScope(fs)
+	s.Durable = true
+	_, err := s.TempFileScope("/dir", "bar", "/dir/baz", func(f afero.File) (bool, error) {
+		_, err := f.WriteString("hello")
+		return true, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"sync file", "close file", "rename baz", "sync dir /dir"}
+	if !reflect.DeepEqual(log.ops, expected) {
+		t.Fatalf("invalid operations: %v", log.ops)
+	}
+
+	log.ops = nil
+	s.Durable = false
+	_, err = s.TempFileScope("/dir", "bar", "/dir/baz", func(f afero.File) (bool, error) {
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"close file", "rename baz"}
+	if !reflect.DeepEqual(log.ops, expected) {
+		t.Fatalf("invalid operations: %v", log.ops)
+	}
+}
+
+func TestTempFileScopeDurableUnsupported(t *testing.T) {
+	log := &syncLog{dirSync: &os.PathError{Op: "sync", Path: "/dir", Err: syscall.EINVAL}}
+	fs := &syncRecordingFs{Fs: afero.NewMemMapFs(), log: log}
+	fs.MkdirAll("/dir", 0755)
+	s := ose.NewTempScope(fs)
+	s.Durable = true
+	_, err := s.TempFileScope("/dir", "bar", "/dir/baz", func(f afero.File) (bool, error) {
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("unsupported directory sync must be ignored: %v", err)
+	}
+
+	log.dirSync = syscall.EIO
+	_, err = s.TempFileScope("/dir", "bar", "/dir/baz", func(f afero.File) (bool, error) {
+		return true, nil
+	})
+	if err != syscall.EIO {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
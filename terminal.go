@@ -0,0 +1,171 @@
+package ose
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var ErrNotTerminal = errors.New("not a terminal")
+
+// ColorMode corresponds to --color=auto|always|never.
+type ColorMode int
+
+const (
+	ColorAuto ColorMode = iota
+	ColorAlways
+	ColorNever
+)
+
+func ParseColorMode(s string) (ColorMode, error) {
+	switch s {
+	case "", "auto", "tty", "if-tty":
+		return ColorAuto, nil
+	case "always", "yes", "force":
+		return ColorAlways, nil
+	case "never", "no", "none":
+		return ColorNever, nil
+	}
+	return ColorAuto, fmt.Errorf("invalid color mode: %s", s)
+}
+
+func (m ColorMode) String() string {
+	switch m {
+	case ColorAlways:
+		return "always"
+	case ColorNever:
+		return "never"
+	}
+	return "auto"
+}
+
+// TerminalIO is an IO which knows about the terminal connected to it.
+type TerminalIO interface {
+	IO
+	IsTerminal(s Stream) bool
+	Size(s Stream) (width, height int, err error)
+	ColorEnabled(s Stream) bool
+	SetColorMode(m ColorMode)
+}
+
+// IsTerminal reports whether the stream s of io is a terminal. It returns false unless io is a TerminalIO.
+func IsTerminal(io IO, s Stream) bool {
+	if tio, ok := io.(TerminalIO); ok {
+		return tio.IsTerminal(s)
+	}
+	return false
+}
+
+// TerminalSize returns the size of the terminal connected to the stream s of io.
+func TerminalSize(io IO, s Stream) (width, height int, err error) {
+	if tio, ok := io.(TerminalIO); ok {
+		return tio.Size(s)
+	}
+	return 0, 0, ErrNotTerminal
+}
+
+// ColorEnabled reports whether colored output should be written to the stream s of io.
+func ColorEnabled(io IO, s Stream) bool {
+	if tio, ok := io.(TerminalIO); ok {
+		return tio.ColorEnabled(s)
+	}
+	return false
+}
+
+// SetColorMode sets m to io if io is a TerminalIO.
+func SetColorMode(io IO, m ColorMode) bool {
+	if tio, ok := io.(TerminalIO); ok {
+		tio.SetColorMode(m)
+		return true
+	}
+	return false
+}
+
+// ResolveColor decides whether colors are used in mode.
+// In ColorAuto, NO_COLOR and TERM=dumb in env disable colors, and otherwise colors are used only on a terminal.
+func ResolveColor(mode ColorMode, env Env, isTerminal bool) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if env != nil {
+		if _, ok := env.Lookup("NO_COLOR"); ok {
+			return false
+		}
+		if env.Get("TERM") == "dumb" {
+			return false
+		}
+	}
+	return isTerminal
+}
+
+func (i *realIO) file(s Stream) *os.File {
+	switch s {
+	case StreamIn:
+		return os.Stdin
+	case StreamOut:
+		return os.Stdout
+	case StreamErr:
+		return os.Stderr
+	}
+	return nil
+}
+
+func (i *realIO) IsTerminal(s Stream) bool {
+	f := i.file(s)
+	return f != nil && terminal.IsTerminal(int(f.Fd()))
+}
+
+func (i *realIO) Size(s Stream) (width, height int, err error) {
+	if !i.IsTerminal(s) {
+		return 0, 0, ErrNotTerminal
+	}
+	return terminal.GetSize(int(i.file(s).Fd()))
+}
+
+func (i *realIO) ColorEnabled(s Stream) bool {
+	m := ColorMode(atomic.LoadInt32(&i.colorMode))
+	return ResolveColor(m, RealEnv{}, i.IsTerminal(s))
+}
+
+func (i *realIO) SetColorMode(m ColorMode) { atomic.StoreInt32(&i.colorMode, int32(m)) }
+
+func (i *BufIOContainer) IsTerminal(s Stream) bool {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.isTerminal(s)
+}
+
+func (i *BufIOContainer) isTerminal(s Stream) bool {
+	if ok, found := i.TerminalStreams[s]; found {
+		return ok
+	}
+	return i.Terminal
+}
+
+func (i *BufIOContainer) Size(s Stream) (width, height int, err error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if !i.isTerminal(s) {
+		return 0, 0, ErrNotTerminal
+	}
+	return i.Width, i.Height, nil
+}
+
+func (i *BufIOContainer) ColorEnabled(s Stream) bool {
+	i.mutex.Lock()
+	m, env, tty := i.ColorMode, i.Env, i.isTerminal(s)
+	i.mutex.Unlock()
+	return ResolveColor(m, env, tty)
+}
+
+func (i *BufIOContainer) SetColorMode(m ColorMode) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.ColorMode = m
+}
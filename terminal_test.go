@@ -0,0 +1,117 @@
+package ose_test
+
+import (
+	"testing"
+
+	"github.com/taskie/ose"
+)
+
+func TestParseColorMode(t *testing.T) {
+	for s, expected := range map[string]ose.ColorMode{
+		"":       ose.ColorAuto,
+		"auto":   ose.ColorAuto,
+		"always": ose.ColorAlways,
+		"never":  ose.ColorNever,
+	} {
+		m, err := ose.ParseColorMode(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m != expected {
+			t.Fatalf("invalid mode: %v (expected: %v)", m, expected)
+		}
+	}
+	_, err := ose.ParseColorMode("sometimes")
+	if err == nil {
+		t.Fatal("must fail")
+	}
+}
+
+func TestBufIOContainerTerminal(t *testing.T) {
+	w := ose.NewFakeWorld()
+	if ose.IsTerminal(w.IO(), ose.StreamOut) {
+		t.Fatal("must not be a terminal")
+	}
+	if _, _, err := ose.TerminalSize(w.IO(), ose.StreamOut); err != ose.ErrNotTerminal {
+		t.Fatalf("invalid error: %v", err)
+	}
+	if ose.ColorEnabled(w.IO(), ose.StreamOut) {
+		t.Fatal("color must be disabled")
+	}
+
+	w.FakeIO.Terminal = true
+	width, height, err := ose.TerminalSize(w.IO(), ose.StreamOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if width != 80 || height != 24 {
+		t.Fatalf("invalid size: %dx%d", width, height)
+	}
+	if !ose.ColorEnabled(w.IO(), ose.StreamOut) {
+		t.Fatal("color must be enabled")
+	}
+	_ = w.FakeEnv.Set("TERM", "dumb")
+	if ose.ColorEnabled(w.IO(), ose.StreamOut) {
+		t.Fatal("color must be disabled by TERM=dumb")
+	}
+	_ = w.FakeEnv.Unset("TERM")
+	_ = w.FakeEnv.Set("NO_COLOR", "")
+	if ose.ColorEnabled(w.IO(), ose.StreamOut) {
+		t.Fatal("color must be disabled by NO_COLOR")
+	}
+	ose.SetColorMode(w.IO(), ose.ColorAlways)
+	if !ose.ColorEnabled(w.IO(), ose.StreamOut) {
+		t.Fatal("color must be enabled by --color=always")
+	}
+}
+
+func TestBufIOContainerTerminalStreams(t *testing.T) {
+	w := ose.NewFakeWorld()
+	w.FakeIO.Terminal = true
+	w.FakeIO.TerminalStreams = map[ose.Stream]bool{ose.StreamOut: false}
+	if !ose.IsTerminal(w.IO(), ose.StreamIn) || !ose.IsTerminal(w.IO(), ose.StreamErr) {
+		t.Fatal("must be a terminal")
+	}
+	if ose.IsTerminal(w.IO(), ose.StreamOut) {
+		t.Fatal("must not be a terminal")
+	}
+	if _, _, err := ose.TerminalSize(w.IO(), ose.StreamOut); err != ose.ErrNotTerminal {
+		t.Fatalf("invalid error: %v", err)
+	}
+	if ose.ColorEnabled(w.IO(), ose.StreamOut) || !ose.ColorEnabled(w.IO(), ose.StreamErr) {
+		t.Fatal("color must be enabled only for stderr")
+	}
+}
+
+func TestRealIOTerminal(t *testing.T) {
+	var _ ose.TerminalIO = ose.NewStdio().(ose.TerminalIO)
+	io := ose.NewStdio()
+	ose.SetColorMode(io, ose.ColorNever)
+	if ose.ColorEnabled(io, ose.StreamOut) {
+		t.Fatal("color must be disabled by --color=never")
+	}
+	done := make(chan struct{})
+	go func() {
+		ose.SetColorMode(io, ose.ColorAlways)
+		close(done)
+	}()
+	ose.ColorEnabled(io, ose.StreamErr)
+	<-done
+	if !ose.ColorEnabled(io, ose.StreamOut) {
+		t.Fatal("color must be enabled by --color=always")
+	}
+}
+
+func TestBufIOContainerColorModeConcurrent(t *testing.T) {
+	io := ose.NewBufIOContainer()
+	done := make(chan struct{})
+	go func() {
+		ose.SetColorMode(io, ose.ColorAlways)
+		close(done)
+	}()
+	ose.ColorEnabled(io, ose.StreamErr)
+	<-done
+	if !ose.ColorEnabled(io, ose.StreamOut) {
+		t.Fatal("color must be enabled by --color=always")
+	}
+}
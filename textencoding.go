@@ -0,0 +1,257 @@
+package ose
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// LookupEncoding returns the encoding of name, like "utf-8", "utf-16", "utf-16le", "shift_jis" or "euc-jp".
+// It accepts the names of the WHATWG Encoding Standard.
+// The encoder of "utf-16" writes a BOM, and the ones of "utf-16le" and "utf-16be" don't.
+func LookupEncoding(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(name) {
+	case "utf-8", "utf8":
+		return unicode.UTF8, nil
+	case "utf-16":
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), nil
+	case "utf-16le":
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), nil
+	case "utf-16be":
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), nil
+	}
+	e, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding: %s", name)
+	}
+	return e, nil
+}
+
+// DetectEncoding guesses the encoding of a sample from a BOM, the validity as UTF-8,
+// and the byte patterns of EUC-JP and Shift_JIS. It returns "" if the sample is valid in none of them.
+func DetectEncoding(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xef, 0xbb, 0xbf}):
+		return "utf-8"
+	case bytes.HasPrefix(sample, []byte{0xff, 0xfe}):
+		return "utf-16le"
+	case bytes.HasPrefix(sample, []byte{0xfe, 0xff}):
+		return "utf-16be"
+	}
+	if validUTF8Prefix(sample) {
+		return "utf-8"
+	}
+	if validEUCJP(sample) {
+		return "euc-jp"
+	}
+	if validShiftJIS(sample) {
+		return "shift_jis"
+	}
+	return ""
+}
+
+// validUTF8Prefix is utf8.Valid which allows a rune cut at the end of a sample.
+func validUTF8Prefix(bs []byte) bool {
+	if utf8.Valid(bs) {
+		return true
+	}
+	for i := 1; i < utf8.UTFMax && i <= len(bs); i++ {
+		if tail := bs[len(bs)-i:]; utf8.RuneStart(tail[0]) && !utf8.FullRune(tail) {
+			return utf8.Valid(bs[:len(bs)-i])
+		}
+	}
+	return false
+}
+
+func validEUCJP(bs []byte) bool {
+	in := func(b, lo, hi byte) bool { return lo <= b && b <= hi }
+	for i := 0; i < len(bs); {
+		b := bs[i]
+		n := 0
+		switch {
+		case b < 0x80:
+			n = 1
+		case in(b, 0xa1, 0xfe):
+			n = 2
+		case b == 0x8e:
+			n = 2
+		case b == 0x8f:
+			n = 3
+		default:
+			return false
+		}
+		for j := 1; j < n; j++ {
+			if i+j >= len(bs) {
+				return true
+			}
+			if !in(bs[i+j], 0xa1, 0xfe) {
+				return false
+			}
+		}
+		i += n
+	}
+	return true
+}
+
+func validShiftJIS(bs []byte) bool {
+	in := func(b, lo, hi byte) bool { return lo <= b && b <= hi }
+	for i := 0; i < len(bs); i++ {
+		b := bs[i]
+		switch {
+		case b < 0x80 || in(b, 0xa1, 0xdf):
+			continue
+		case in(b, 0x81, 0x9f) || in(b, 0xe0, 0xfc):
+			if i+1 >= len(bs) {
+				return true
+			}
+			i++
+			if t := bs[i]; !in(t, 0x40, 0x7e) && !in(t, 0x80, 0xfc) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// crlfToLF converts CRLF to LF.
+type crlfToLF struct{ transform.NopResetter }
+
+func (crlfToLF) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		b := src[nSrc]
+		if b == '\r' {
+			if nSrc+1 >= len(src) && !atEOF {
+				return nDst, nSrc, transform.ErrShortSrc
+			}
+			if nSrc+1 < len(src) && src[nSrc+1] == '\n' {
+				nSrc++
+				continue
+			}
+		}
+		if nDst >= len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		dst[nDst] = b
+		nDst++
+		nSrc++
+	}
+	return nDst, nSrc, nil
+}
+
+// lfToCRLF converts LF to CRLF, keeping CRLF as it is.
+type lfToCRLF struct {
+	cr bool
+}
+
+func (t *lfToCRLF) Reset() { t.cr = false }
+
+func (t *lfToCRLF) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		b := src[nSrc]
+		if b == '\n' && !t.cr {
+			if nDst+1 >= len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			dst[nDst] = '\r'
+			nDst++
+		} else if nDst >= len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		dst[nDst] = b
+		nDst++
+		nSrc++
+		t.cr = b == '\r'
+	}
+	return nDst, nSrc, nil
+}
+
+func (o *Opener) textOptions() (encoding.Encoding, error) {
+	switch o.Newline {
+	case "", "lf", "crlf":
+	default:
+		return nil, fmt.Errorf("invalid newline: %s", o.Newline)
+	}
+	if o.Encoding == "" || o.Encoding == "auto" {
+		return nil, nil
+	}
+	return LookupEncoding(o.Encoding)
+}
+
+// decodeText converts rc into UTF-8 as Encoding and Newline specify.
+func (o *Opener) decodeText(rc io.ReadCloser) (io.ReadCloser, error) {
+	e, err := o.textOptions()
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	if o.Encoding == "" && o.Newline == "" {
+		return rc, nil
+	}
+	var r io.Reader = rc
+	ts := make([]transform.Transformer, 0, 2)
+	if o.Encoding != "" && e == nil {
+		// a single Read doesn't wait for more input from a pipe or a terminal
+		sample := make([]byte, 4096)
+		n, err := rc.Read(sample)
+		if err != nil && err != io.EOF {
+			rc.Close()
+			return nil, err
+		}
+		sample = sample[:n]
+		if name := DetectEncoding(sample); name != "" {
+			e, _ = LookupEncoding(name)
+		}
+		r = io.MultiReader(bytes.NewReader(sample), rc)
+	}
+	if e != nil {
+		ts = append(ts, unicode.BOMOverride(e.NewDecoder()))
+	}
+	if o.Newline != "" {
+		ts = append(ts, crlfToLF{})
+	}
+	if len(ts) != 0 {
+		r = transform.NewReader(r, transform.Chain(ts...))
+	}
+	return NewReadCloser(r, func(_ io.Reader) error {
+		return rc.Close()
+	}), nil
+}
+
+// encodeText converts UTF-8 into Encoding and Newline before writing to wc.
+func (o *Opener) encodeText(wc io.WriteCloser) (io.WriteCloser, error) {
+	e, err := o.textOptions()
+	if err != nil {
+		wc.Close()
+		return nil, err
+	}
+	ts := make([]transform.Transformer, 0, 2)
+	if o.Newline == "crlf" {
+		ts = append(ts, &lfToCRLF{})
+	} else if o.Newline == "lf" {
+		ts = append(ts, crlfToLF{})
+	}
+	if e != nil {
+		ts = append(ts, e.NewEncoder())
+	}
+	if len(ts) == 0 {
+		return wc, nil
+	}
+	tw := transform.NewWriter(wc, transform.Chain(ts...))
+	return NewWriteCloser(tw, func(_ io.Writer) error {
+		err := tw.Close()
+		err2 := wc.Close()
+		if err != nil {
+			return err
+		}
+		return err2
+	}), nil
+}
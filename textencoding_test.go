@@ -0,0 +1,214 @@
+package ose_test
+
+import (
+	"bufio"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+var (
+	// "日本\r\n" in some encodings
+	sjisNihon    = []byte{0x93, 0xfa, 0x96, 0x7b, '\r', '\n'}
+	eucjpNihon   = []byte{0xc6, 0xfc, 0xcb, 0xdc, '\r', '\n'}
+	utf16leNihon = []byte{0xff, 0xfe, 0xe5, 0x65, 0x2c, 0x67, '\r', 0, '\n', 0}
+	utf8BOMNihon = []byte{0xef, 0xbb, 0xbf, 0xe6, 0x97, 0xa5, 0xe6, 0x9c, 0xac, '\r', '\n'}
+)
+
+func TestDetectEncoding(t *testing.T) {
+	cases := []struct {
+		sample   []byte
+		expected string
+	}{
+		{sjisNihon, "shift_jis"},
+		{eucjpNihon, "euc-jp"},
+		{utf16leNihon, "utf-16le"},
+		{utf8BOMNihon, "utf-8"},
+		{[]byte("日本")[:4], "utf-8"},
+		{[]byte("hello"), "utf-8"},
+		{sjisNihon[:3], "shift_jis"},
+		{[]byte("caf\xe9\n"), ""},
+		{[]byte{0x80, 0xfd}, ""},
+	}
+	for _, c := range cases {
+		if e := ose.DetectEncoding(c.sample); e != c.expected {
+			t.Fatalf("invalid encoding: %v: %s", c.sample, e)
+		}
+	}
+}
+
+func TestOpenerEncodingRead(t *testing.T) {
+	cases := []struct {
+		encoding string
+		data     []byte
+	}{
+		{"shift_jis", sjisNihon},
+		{"euc-jp", eucjpNihon},
+		{"utf-16", utf16leNihon},
+		{"auto", sjisNihon},
+		{"auto", eucjpNihon},
+		{"auto", utf16leNihon},
+		{"auto", utf8BOMNihon},
+	}
+	for _, c := range cases {
+		w := ose.NewFakeWorld()
+		afero.WriteFile(w.FakeFs, "foo.txt", c.data, 0644)
+		o := ose.NewOpenerInWorld(w)
+		o.Encoding = c.encoding
+		o.Newline = "lf"
+		if s := readAll(t, o, "foo.txt"); s != "日本\n" {
+			t.Fatalf("invalid content: %s: %q", c.encoding, s)
+		}
+	}
+}
+
+func TestOpenerEncodingUnknown(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "foo.txt", []byte("caf\xe9\r\n"), 0644)
+	o := ose.NewOpenerInWorld(w)
+	o.Encoding = "auto"
+	o.Newline = "lf"
+	if s := readAll(t, o, "foo.txt"); s != "caf\xe9\n" {
+		t.Fatalf("invalid content: %q", s)
+	}
+}
+
+// pipeIO reads In from a pipe.
+type pipeIO struct {
+	*ose.BufIOContainer
+	r io.Reader
+}
+
+func (i pipeIO) In() io.Reader { return i.r }
+
+func TestOpenerEncodingPipe(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go pw.Write(sjisNihon)
+	o := ose.NewOpener(afero.NewMemMapFs(), pipeIO{ose.NewBufIOContainer(), pr})
+	o.Encoding = "auto"
+	o.Unbuffered = true
+	done := make(chan string, 1)
+	go func() {
+		rc, err := o.Open("-")
+		if err != nil {
+			done <- err.Error()
+			return
+		}
+		s, _ := bufio.NewReader(rc).ReadString('\n')
+		done <- s
+	}()
+	select {
+	case s := <-done:
+		if s != "日本\r\n" {
+			t.Fatalf("invalid content: %q", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("must not wait for more input")
+	}
+}
+
+func TestOpenerEncodingWrite(t *testing.T) {
+	cases := []struct {
+		encoding string
+		expected []byte
+	}{
+		{"shift_jis", sjisNihon},
+		{"euc-jp", eucjpNihon},
+		{"utf-16", utf16leNihon},
+	}
+	for _, c := range cases {
+		w := ose.NewFakeWorld()
+		o := ose.NewOpenerInWorld(w)
+		o.Encoding = c.encoding
+		o.Newline = "crlf"
+		wc, err := o.Create("foo.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(wc, "日本\n")
+		err = wc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, err := afero.ReadFile(w.FakeFs, "foo.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != string(c.expected) {
+			t.Fatalf("invalid content: %s: %v", c.encoding, bs)
+		}
+		if s := readAll(t, o, "foo.txt"); s != "日本\n" {
+			t.Fatalf("invalid round trip: %s: %q", c.encoding, s)
+		}
+	}
+}
+
+func TestOpenerEncodingStdio(t *testing.T) {
+	w := ose.NewFakeWorld()
+	w.FakeIO.WriteInString(string(sjisNihon) + "foo\r\nbar\r")
+	o := ose.NewOpenerInWorld(w)
+	o.Encoding = "auto"
+	o.Newline = "lf"
+	if s := readAll(t, o, "-"); s != "日本\nfoo\nbar\r" {
+		t.Fatalf("invalid content: %q", s)
+	}
+
+	o.Encoding = "euc-jp"
+	o.Newline = "crlf"
+	wc, err := o.Create("-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "日本\nfoo\r\n")
+	err = wc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := w.FakeIO.OutString(); s != string(eucjpNihon)+"foo\r\n" {
+		t.Fatalf("invalid output: %v", []byte(s))
+	}
+}
+
+func TestOpenerEncodingTempFile(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.Encoding = "shift_jis"
+	o.Newline = "crlf"
+	_, err := o.CreateTempFile("", "foo", "foo.txt", func(wc io.WriteCloser) (bool, error) {
+		_, err := io.WriteString(wc, "日本\n")
+		return true, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := afero.ReadFile(w.FakeFs, "foo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != string(sjisNihon) {
+		t.Fatalf("invalid content: %v", bs)
+	}
+}
+
+func TestOpenerEncodingInvalid(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.Encoding = "no-such-encoding"
+	_, err := o.Create("foo.txt")
+	if err == nil {
+		t.Fatal("must fail")
+	}
+	if ok, _ := afero.Exists(w.FakeFs, "foo.txt"); ok {
+		t.Fatal("must not create foo.txt")
+	}
+	o.Encoding = ""
+	o.Newline = "cr"
+	_, err = o.Create("foo.txt")
+	if err == nil {
+		t.Fatal("must fail")
+	}
+}
@@ -0,0 +1,175 @@
+package ose
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// TimeoutError is returned when a read or a write stalls longer than the timeout, or passes the deadline.
+// Duration is zero if the deadline is exceeded.
+type TimeoutError struct {
+	Op       string
+	Duration time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Duration <= 0 {
+		return fmt.Sprintf("%s: deadline exceeded", e.Op)
+	}
+	return fmt.Sprintf("%s: timed out after %s", e.Op, e.Duration)
+}
+
+// Timeout reports true like net.Error, so that os.IsTimeout works.
+func (e *TimeoutError) Timeout() bool { return true }
+
+type timeoutResult struct {
+	n   int
+	err error
+}
+
+// deadlineTimer waits for an operation within the timeout and the deadline.
+type deadlineTimer struct {
+	Timeout  time.Duration
+	clock    Clock
+	mutex    sync.Mutex
+	deadline time.Time
+}
+
+func newDeadlineTimer(timeout time.Duration, clock Clock) deadlineTimer {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return deadlineTimer{Timeout: timeout, clock: clock}
+}
+
+// SetDeadline makes operations fail after t. The zero value means no deadline.
+func (d *deadlineTimer) SetDeadline(t time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.deadline = t
+}
+
+func (d *deadlineTimer) wait(op string, ch <-chan timeoutResult) (timeoutResult, error) {
+	d.mutex.Lock()
+	deadline := d.deadline
+	d.mutex.Unlock()
+	wait, timeout := d.Timeout, d.Timeout
+	if !deadline.IsZero() {
+		rest := deadline.Sub(d.clock.Now())
+		if wait <= 0 || rest < wait {
+			wait, timeout = rest, 0
+		}
+		if wait <= 0 {
+			select {
+			case r := <-ch:
+				return r, nil
+			default:
+				return timeoutResult{}, &TimeoutError{Op: op}
+			}
+		}
+	} else if wait <= 0 {
+		return <-ch, nil
+	}
+	t := d.clock.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case r := <-ch:
+		return r, nil
+	case <-t.C():
+		return timeoutResult{}, &TimeoutError{Op: op, Duration: timeout}
+	}
+}
+
+// TimeoutReadCloser implements io.ReadCloser.
+// Read fails with a *TimeoutError if the underlying ReadCloser stalls longer than Timeout or passes the deadline.
+// The stalled read continues in background, and its result is returned by the next Read.
+// Close closes the underlying ReadCloser to end the stalled read, but the background goroutine remains
+// until the read returns if the ReadCloser doesn't interrupt it, like os.Stdin on some platforms.
+type TimeoutReadCloser struct {
+	deadlineTimer
+	ReadCloser io.ReadCloser
+	pending    chan timeoutResult
+	buf        []byte
+	rest       []byte
+	restErr    error
+}
+
+// NewTimeoutReadCloser wraps rc. A zero timeout means no timeout. A nil clock means the real clock.
+func NewTimeoutReadCloser(rc io.ReadCloser, timeout time.Duration, clock Clock) *TimeoutReadCloser {
+	return &TimeoutReadCloser{deadlineTimer: newDeadlineTimer(timeout, clock), ReadCloser: rc}
+}
+
+func (trc *TimeoutReadCloser) Read(p []byte) (int, error) {
+	if len(trc.rest) != 0 || trc.restErr != nil {
+		n := copy(p, trc.rest)
+		trc.rest = trc.rest[n:]
+		if len(trc.rest) != 0 {
+			return n, nil
+		}
+		err := trc.restErr
+		trc.restErr = nil
+		return n, err
+	}
+	if trc.pending == nil {
+		if cap(trc.buf) < len(p) {
+			trc.buf = make([]byte, len(p))
+		}
+		buf := trc.buf[:len(p)]
+		ch := make(chan timeoutResult, 1)
+		go func() {
+			n, err := trc.ReadCloser.Read(buf)
+			ch <- timeoutResult{n: n, err: err}
+		}()
+		trc.pending = ch
+	}
+	r, err := trc.wait("read", trc.pending)
+	if err != nil {
+		return 0, err
+	}
+	trc.pending = nil
+	trc.rest, trc.restErr = trc.buf[:r.n], r.err
+	return trc.Read(p)
+}
+
+func (trc *TimeoutReadCloser) Close() error {
+	return trc.ReadCloser.Close()
+}
+
+// TimeoutWriteCloser implements io.WriteCloser.
+// Write fails with a *TimeoutError if the underlying WriteCloser stalls longer than Timeout or passes the deadline.
+// After a timeout, the state of the stream is unknown, so every Write fails with the same error.
+// The stalled write remains in background until it returns, as a stalled read of TimeoutReadCloser does.
+type TimeoutWriteCloser struct {
+	deadlineTimer
+	WriteCloser io.WriteCloser
+	err         error
+}
+
+// NewTimeoutWriteCloser wraps wc. A zero timeout means no timeout. A nil clock means the real clock.
+func NewTimeoutWriteCloser(wc io.WriteCloser, timeout time.Duration, clock Clock) *TimeoutWriteCloser {
+	return &TimeoutWriteCloser{deadlineTimer: newDeadlineTimer(timeout, clock), WriteCloser: wc}
+}
+
+func (twc *TimeoutWriteCloser) Write(p []byte) (int, error) {
+	if twc.err != nil {
+		return 0, twc.err
+	}
+	buf := append([]byte{}, p...)
+	ch := make(chan timeoutResult, 1)
+	go func() {
+		n, err := twc.WriteCloser.Write(buf)
+		ch <- timeoutResult{n: n, err: err}
+	}()
+	r, err := twc.wait("write", ch)
+	if err != nil {
+		twc.err = err
+		return 0, err
+	}
+	return r.n, r.err
+}
+
+func (twc *TimeoutWriteCloser) Close() error {
+	return twc.WriteCloser.Close()
+}
@@ -0,0 +1,84 @@
+package ose_test
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/taskie/ose"
+)
+
+func TestTimeoutReadCloser(t *testing.T) {
+	clock := ose.NewFakeClock(epoch, 0)
+	pr, pw := io.Pipe()
+	rc := ose.NewTimeoutReadCloser(pr, 5*time.Second, clock)
+	buf := make([]byte, 10)
+	errs := make(chan error)
+	go func() {
+		_, err := rc.Read(buf)
+		errs <- err
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	err := <-errs
+	if terr, ok := err.(*ose.TimeoutError); !ok || terr.Op != "read" || terr.Duration != 5*time.Second {
+		t.Fatalf("invalid error: %v", err)
+	}
+	if !os.IsTimeout(err) {
+		t.Fatal("must be a timeout")
+	}
+
+	go io.WriteString(pw, "abc")
+	n, err := rc.Read(buf)
+	if err != nil || string(buf[:n]) != "abc" {
+		t.Fatalf("the stalled read must be resumed: %q, %v", buf[:n], err)
+	}
+
+	rc.SetDeadline(epoch.Add(-time.Second))
+	_, err = rc.Read(buf)
+	if terr, ok := err.(*ose.TimeoutError); !ok || terr.Duration != 0 {
+		t.Fatalf("invalid error: %v", err)
+	}
+	rc.Close()
+}
+
+func TestTimeoutReadCloserShortBuffer(t *testing.T) {
+	pr, pw := io.Pipe()
+	rc := ose.NewTimeoutReadCloser(pr, time.Hour, nil)
+	go func() {
+		io.WriteString(pw, "abcdef")
+		pw.Close()
+	}()
+	buf := make([]byte, 4)
+	n, err := rc.Read(buf)
+	if err != nil || string(buf[:n]) != "abcd" {
+		t.Fatalf("invalid read: %q, %v", buf[:n], err)
+	}
+	n, err = rc.Read(buf[:1])
+	if err != nil || string(buf[:n]) != "e" {
+		t.Fatalf("invalid read: %q, %v", buf[:n], err)
+	}
+}
+
+func TestTimeoutWriteCloser(t *testing.T) {
+	clock := ose.NewFakeClock(epoch, 0)
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	wc := ose.NewTimeoutWriteCloser(pw, time.Second, clock)
+	errs := make(chan error)
+	go func() {
+		_, err := wc.Write([]byte("foo"))
+		errs <- err
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	err := <-errs
+	if terr, ok := err.(*ose.TimeoutError); !ok || terr.Op != "write" {
+		t.Fatalf("invalid error: %v", err)
+	}
+	_, err2 := wc.Write([]byte("bar"))
+	if err2 != err {
+		t.Fatalf("invalid error: %v", err2)
+	}
+}
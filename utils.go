@@ -1,5 +1,7 @@
 package ose
 
+import "sort"
+
 func rejectEmpty(ss []string) []string {
 	results := make([]string, 0)
 	for _, s := range ss {
@@ -9,3 +11,12 @@ func rejectEmpty(ss []string) []string {
 	}
 	return results
 }
+
+func environ(m map[string]string) []string {
+	results := make([]string, 0, len(m))
+	for k, v := range m {
+		results = append(results, k+"="+v)
+	}
+	sort.Strings(results)
+	return results
+}
@@ -2,23 +2,57 @@ package ose
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/mattn/go-colorable"
 	"github.com/spf13/afero"
 )
 
-var world World
+var (
+	world      World
+	worldMutex sync.RWMutex
+)
 
 func init() {
 	SetWorld(NewRealWorld())
 }
 
-func GetWorld() World  { return world }
-func SetWorld(w World) { world = w }
+// GetWorld returns the global World, which is the fallback of WorldFrom.
+func GetWorld() World {
+	worldMutex.RLock()
+	defer worldMutex.RUnlock()
+	return world
+}
+
+// SetWorld replaces the global World.
+// Prefer WithWorld in tests running in parallel.
+func SetWorld(w World) {
+	worldMutex.Lock()
+	defer worldMutex.Unlock()
+	world = w
+}
+
+type worldKey struct{}
+
+// WithWorld returns a copy of ctx which carries w.
+func WithWorld(ctx context.Context, w World) context.Context {
+	return context.WithValue(ctx, worldKey{}, w)
+}
+
+// WorldFrom returns the World carried by ctx, or the global World if ctx has none.
+func WorldFrom(ctx context.Context) World {
+	if ctx != nil {
+		if w, ok := ctx.Value(worldKey{}).(World); ok && w != nil {
+			return w
+		}
+	}
+	return GetWorld()
+}
 
 type IO interface {
 	In() io.Reader
@@ -26,11 +60,16 @@ type IO interface {
 	Err() io.Writer
 }
 
-type realIO struct{}
+type realIO struct {
+	out io.Writer
+	err io.Writer
+	// colorMode is a ColorMode accessed atomically.
+	colorMode int32
+}
 
-func (realIO) In() io.Reader  { return os.Stdin }
-func (realIO) Out() io.Writer { return os.Stdout }
-func (realIO) Err() io.Writer { return os.Stderr }
+func (i *realIO) In() io.Reader  { return os.Stdin }
+func (i *realIO) Out() io.Writer { return i.out }
+func (i *realIO) Err() io.Writer { return i.err }
 
 type IOContainer struct {
 	InR  io.Reader
@@ -54,26 +93,92 @@ func NewStdio() IO {
 	if runtime.GOOS == "windows" {
 		outW := colorable.NewColorableStdout()
 		errW := colorable.NewColorableStderr()
-		return NewIOContainer(os.Stdin, outW, errW)
+		return &realIO{out: outW, err: errW}
 	}
-	return realIO{}
+	return &realIO{out: os.Stdout, err: os.Stderr}
 }
 
+// BufIOContainer is an IO backed by buffers.
+// In, Out and Err return synchronized wrappers of InBuf, OutBuf and ErrBuf, not the buffers themselves,
+// so they are safe for concurrent use, but type assertions like Out().(*bytes.Buffer) fail.
+// Access the buffers directly only in WithLock while other goroutines use the IO.
+// Terminal, TerminalStreams, Width, Height, ColorMode and Env control the capabilities reported as a TerminalIO.
+// The methods read and SetColorMode writes them holding the lock, so change them in WithLock as well while the IO is in use.
 type BufIOContainer struct {
-	InBuf  *bytes.Buffer
-	OutBuf *bytes.Buffer
-	ErrBuf *bytes.Buffer
+	InBuf    *bytes.Buffer
+	OutBuf   *bytes.Buffer
+	ErrBuf   *bytes.Buffer
+	Terminal bool
+	// TerminalStreams overrides Terminal for each stream, e.g. to redirect Out while In is a terminal.
+	TerminalStreams map[Stream]bool
+	Width           int
+	Height          int
+	ColorMode       ColorMode
+	Env             Env
+	mutex           sync.Mutex
+}
+
+func (i *BufIOContainer) In() io.Reader  { return &lockedReader{r: i.InBuf, mutex: &i.mutex} }
+func (i *BufIOContainer) Out() io.Writer { return &lockedWriter{w: i.OutBuf, mutex: &i.mutex} }
+func (i *BufIOContainer) Err() io.Writer { return &lockedWriter{w: i.ErrBuf, mutex: &i.mutex} }
+
+// WithLock calls f holding the lock of the buffers.
+func (i *BufIOContainer) WithLock(f func()) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	f()
+}
+
+// OutString returns the content of OutBuf.
+func (i *BufIOContainer) OutString() string {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.OutBuf.String()
+}
+
+// ErrString returns the content of ErrBuf.
+func (i *BufIOContainer) ErrString() string {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.ErrBuf.String()
+}
+
+// WriteInString appends s to InBuf.
+func (i *BufIOContainer) WriteInString(s string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.InBuf.WriteString(s)
 }
 
-func (i *BufIOContainer) In() io.Reader  { return i.InBuf }
-func (i *BufIOContainer) Out() io.Writer { return i.OutBuf }
-func (i *BufIOContainer) Err() io.Writer { return i.ErrBuf }
+type lockedReader struct {
+	r     io.Reader
+	mutex *sync.Mutex
+}
+
+func (r *lockedReader) Read(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.r.Read(p)
+}
+
+type lockedWriter struct {
+	w     io.Writer
+	mutex *sync.Mutex
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.w.Write(p)
+}
 
 func NewBufIOContainer() *BufIOContainer {
 	return &BufIOContainer{
 		InBuf:  new(bytes.Buffer),
 		OutBuf: new(bytes.Buffer),
 		ErrBuf: new(bytes.Buffer),
+		Width:  80,
+		Height: 24,
 	}
 }
 
@@ -81,122 +186,188 @@ type Env interface {
 	Get(key string) string
 	Lookup(key string) (string, bool)
 	Set(key string, value string) error
+	Unset(key string) error
 	Clear()
+	// Environ returns "key=value" pairs like os.Environ.
+	Environ() []string
+	// Expand replaces $var or ${var} in s like os.ExpandEnv.
+	Expand(s string) string
 }
 
-type realEnv struct{}
+// RealEnv is the Env of the process.
+type RealEnv struct{}
 
-func (realEnv) Get(key string) string              { return os.Getenv(key) }
-func (realEnv) Lookup(key string) (string, bool)   { return os.LookupEnv(key) }
-func (realEnv) Set(key string, value string) error { return os.Setenv(key, value) }
-func (realEnv) Clear()                             { os.Clearenv() }
+func (RealEnv) Get(key string) string              { return os.Getenv(key) }
+func (RealEnv) Lookup(key string) (string, bool)   { return os.LookupEnv(key) }
+func (RealEnv) Set(key string, value string) error { return os.Setenv(key, value) }
+func (RealEnv) Unset(key string) error             { return os.Unsetenv(key) }
+func (RealEnv) Clear()                             { os.Clearenv() }
+func (RealEnv) Environ() []string                  { return os.Environ() }
+func (RealEnv) Expand(s string) string             { return os.ExpandEnv(s) }
 
+// MapEnv is an Env backed by a map. It is safe for concurrent use.
 type MapEnv struct {
-	m map[string]string
+	m     map[string]string
+	mutex sync.RWMutex
 }
 
 func NewMapEnv() *MapEnv {
 	return &MapEnv{m: make(map[string]string)}
 }
 
-func (e *MapEnv) Get(key string) string            { return e.m[key] }
-func (e *MapEnv) Lookup(key string) (string, bool) { v, ok := e.m[key]; return v, ok }
+func (e *MapEnv) Get(key string) string {
+	v, _ := e.Lookup(key)
+	return v
+}
+
+func (e *MapEnv) Lookup(key string) (string, bool) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	v, ok := e.m[key]
+	return v, ok
+}
+
 func (e *MapEnv) Set(key string, value string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
 	e.m[key] = value
 	return nil
 }
-func (e *MapEnv) Clear() { e.m = make(map[string]string) }
 
-func (e *MapEnv) GetMap() map[string]string  { return e.m }
-func (e *MapEnv) SetMap(m map[string]string) { e.m = m }
+func (e *MapEnv) Unset(key string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	delete(e.m, key)
+	return nil
+}
+
+func (e *MapEnv) Clear() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.m = make(map[string]string)
+}
 
-// see https://stackoverflow.com/questions/18970265
-type Clock interface {
-	Now() time.Time
-	After(d time.Duration) <-chan time.Time
+// Environ returns "key=value" pairs sorted by key.
+func (e *MapEnv) Environ() []string {
+	return environ(e.Snapshot())
 }
 
-type realClock struct{}
+func (e *MapEnv) Expand(s string) string {
+	return os.Expand(s, e.Get)
+}
 
-func (realClock) Now() time.Time                         { return time.Now() }
-func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+// GetMap returns the underlying map. Accessing it is not synchronized, so use Snapshot while other goroutines use the MapEnv.
+func (e *MapEnv) GetMap() map[string]string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.m
+}
 
-type FakeClock struct {
-	Time     time.Time
-	Duration time.Duration
-	Count    int64
+// Snapshot returns a copy of the underlying map.
+func (e *MapEnv) Snapshot() map[string]string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return copyStringMap(e.m)
 }
 
-func NewFakeClock(t time.Time, d time.Duration) *FakeClock {
-	return &FakeClock{Time: t, Duration: d}
+// SetMap replaces the underlying map with m itself, not a copy.
+// The MapEnv and the caller share m, so modifying m afterwards is not synchronized either.
+func (e *MapEnv) SetMap(m map[string]string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.m = m
 }
 
-func (c *FakeClock) Now() time.Time {
-	t := c.Time.Add(time.Duration(c.Count) * c.Duration)
-	c.Count++
-	return t
+func copyStringMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
 }
-func (c *FakeClock) After(d time.Duration) <-chan time.Time { return time.After(c.Duration) }
 
 type World interface {
 	Fs() afero.Fs
 	IO() IO
 	Env() Env
 	Clock() Clock
+	Commander() Commander
 }
 
 type WorldContainer struct {
-	fs    afero.Fs
-	io    IO
-	env   Env
-	clock Clock
+	fs        afero.Fs
+	io        IO
+	env       Env
+	clock     Clock
+	commander Commander
 }
 
+// NewWorldContainer creates a WorldContainer whose Commander is backed by os/exec with io and env.
 func NewWorldContainer(fs afero.Fs, io IO, env Env, clock Clock) *WorldContainer {
-	return &WorldContainer{fs: fs, io: io, env: env, clock: clock}
+	return &WorldContainer{fs: fs, io: io, env: env, clock: clock, commander: NewCommander(io, env)}
 }
 
-func (w *WorldContainer) Fs() afero.Fs { return w.fs }
-func (w *WorldContainer) IO() IO       { return w.io }
-func (w *WorldContainer) Env() Env     { return w.env }
-func (w *WorldContainer) Clock() Clock { return w.clock }
+func (w *WorldContainer) Fs() afero.Fs         { return w.fs }
+func (w *WorldContainer) IO() IO               { return w.io }
+func (w *WorldContainer) Env() Env             { return w.env }
+func (w *WorldContainer) Clock() Clock         { return w.clock }
+func (w *WorldContainer) Commander() Commander { return w.commander }
+
+func (w *WorldContainer) SetCommander(c Commander) { w.commander = c }
 
 type realWorld struct {
-	fs afero.Fs
-	io IO
+	fs        afero.Fs
+	io        IO
+	commander Commander
 }
 
 func NewRealWorld() World {
-	return &realWorld{fs: afero.NewOsFs(), io: NewStdio()}
+	fs := afero.NewOsFs()
+	io := NewStdio()
+	return &realWorld{fs: fs, io: io, commander: NewCommander(io, RealEnv{})}
 }
 
-func (w *realWorld) Fs() afero.Fs { return w.fs }
-func (w *realWorld) IO() IO       { return w.io }
-func (w *realWorld) Env() Env     { return realEnv{} }
-func (w *realWorld) Clock() Clock { return realClock{} }
+func (w *realWorld) Fs() afero.Fs         { return w.fs }
+func (w *realWorld) IO() IO               { return w.io }
+func (w *realWorld) Env() Env             { return RealEnv{} }
+func (w *realWorld) Clock() Clock         { return realClock{} }
+func (w *realWorld) Commander() Commander { return w.commander }
 
 type FakeWorld struct {
-	FakeFs    afero.Fs
-	FakeIO    *BufIOContainer
-	FakeEnv   *MapEnv
-	FakeClock *FakeClock
+	FakeFs        afero.Fs
+	FakeIO        *BufIOContainer
+	FakeEnv       *MapEnv
+	FakeClock     *FakeClock
+	FakeCommander *FakeCommander
 }
 
 func NewFakeWorld() *FakeWorld {
+	fakeIO := NewBufIOContainer()
+	fakeEnv := NewMapEnv()
+	fakeIO.Env = fakeEnv
 	return &FakeWorld{
-		FakeFs:    afero.NewMemMapFs(),
-		FakeIO:    NewBufIOContainer(),
-		FakeEnv:   NewMapEnv(),
-		FakeClock: NewFakeClock(time.Now(), time.Millisecond),
+		FakeFs:        afero.NewMemMapFs(),
+		FakeIO:        fakeIO,
+		FakeEnv:       fakeEnv,
+		FakeClock:     NewFakeClock(time.Now(), time.Millisecond),
+		FakeCommander: NewFakeCommander(fakeIO),
 	}
 }
 
-func (w *FakeWorld) Fs() afero.Fs { return w.FakeFs }
-func (w *FakeWorld) IO() IO       { return w.FakeIO }
-func (w *FakeWorld) Env() Env     { return w.FakeEnv }
-func (w *FakeWorld) Clock() Clock { return w.FakeClock }
-
-func GetFs() afero.Fs { return world.Fs() }
-func GetIO() IO       { return world.IO() }
-func GetEnv() Env     { return world.Env() }
-func GetClock() Clock { return world.Clock() }
+func (w *FakeWorld) Fs() afero.Fs         { return w.FakeFs }
+func (w *FakeWorld) IO() IO               { return w.FakeIO }
+func (w *FakeWorld) Env() Env             { return w.FakeEnv }
+func (w *FakeWorld) Clock() Clock         { return w.FakeClock }
+func (w *FakeWorld) Commander() Commander { return w.FakeCommander }
+
+func GetFs() afero.Fs         { return GetWorld().Fs() }
+func GetIO() IO               { return GetWorld().IO() }
+func GetEnv() Env             { return GetWorld().Env() }
+func GetClock() Clock         { return GetWorld().Clock() }
+func GetCommander() Commander { return GetWorld().Commander() }
+
+func FsFrom(ctx context.Context) afero.Fs         { return WorldFrom(ctx).Fs() }
+func IOFrom(ctx context.Context) IO               { return WorldFrom(ctx).IO() }
+func EnvFrom(ctx context.Context) Env             { return WorldFrom(ctx).Env() }
+func ClockFrom(ctx context.Context) Clock         { return WorldFrom(ctx).Clock() }
+func CommanderFrom(ctx context.Context) Commander { return WorldFrom(ctx).Commander() }
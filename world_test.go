@@ -1,7 +1,13 @@
 package ose_test
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/taskie/ose"
 )
@@ -13,3 +19,87 @@ func TestNewRealWorld(t *testing.T) {
 func TestNewFakeWorld(t *testing.T) {
 	var _ ose.World = ose.NewFakeWorld()
 }
+
+func TestWithWorld(t *testing.T) {
+	w := ose.NewFakeWorld()
+	ctx := ose.WithWorld(context.Background(), w)
+	if ose.WorldFrom(ctx) != w {
+		t.Fatal("must be the fake world")
+	}
+	if ose.FsFrom(ctx) != w.FakeFs {
+		t.Fatal("must be the fake fs")
+	}
+	if ose.WorldFrom(context.Background()) != ose.GetWorld() {
+		t.Fatal("must fall back to the global world")
+	}
+}
+
+func TestFakeWorldConcurrency(t *testing.T) {
+	w := ose.NewFakeWorld()
+	w.FakeClock.Duration = time.Second
+	w.FakeIO.WriteInString(strings.Repeat("x", 100))
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("KEY%d", i%10)
+			_ = w.Env().Set(key, "value")
+			_ = w.Env().Get(key)
+			_ = w.FakeEnv.Snapshot()
+			_ = w.FakeClock.GetCount()
+			_, _ = io.WriteString(w.IO().Out(), "o")
+			_, _ = io.WriteString(w.IO().Err(), "e")
+			_, _ = w.IO().In().Read(make([]byte, 1))
+			_ = w.Clock().Now()
+		}(i)
+	}
+	wg.Wait()
+	if len(w.FakeIO.OutString()) != 100 || len(w.FakeIO.ErrString()) != 100 {
+		t.Fatalf("invalid length: %d, %d", len(w.FakeIO.OutString()), len(w.FakeIO.ErrString()))
+	}
+	if w.FakeIO.InBuf.Len() != 0 {
+		t.Fatalf("invalid length: %d", w.FakeIO.InBuf.Len())
+	}
+	if len(w.FakeEnv.GetMap()) != 10 {
+		t.Fatalf("invalid env: %v", w.FakeEnv.GetMap())
+	}
+	if w.FakeClock.Count != 100 {
+		t.Fatalf("invalid count: %d", w.FakeClock.Count)
+	}
+}
+
+func TestMapEnv(t *testing.T) {
+	env := ose.NewMapEnv()
+	_ = env.Set("FOO", "foo")
+	_ = env.Set("BAR", "bar")
+	_ = env.Set("EMPTY", "")
+	if s := strings.Join(env.Environ(), " "); s != "BAR=bar EMPTY= FOO=foo" {
+		t.Fatalf("invalid environ: %s", s)
+	}
+	if s := env.Expand("$FOO-${BAR}-$NONE"); s != "foo-bar-" {
+		t.Fatalf("invalid expansion: %s", s)
+	}
+	_ = env.Unset("FOO")
+	if _, ok := env.Lookup("FOO"); ok {
+		t.Fatal("must be unset")
+	}
+	if _, ok := env.Lookup("EMPTY"); !ok {
+		t.Fatal("must be set")
+	}
+	env.GetMap()["BAZ"] = "baz"
+	if v := env.Get("BAZ"); v != "baz" {
+		t.Fatalf("GetMap must return the underlying map: %q", v)
+	}
+	m := map[string]string{"QUX": "quux"}
+	env.SetMap(m)
+	m["QUX"] = "qux"
+	if v := env.Get("QUX"); v != "qux" {
+		t.Fatalf("SetMap must use the map: %q", v)
+	}
+	snapshot := env.Snapshot()
+	snapshot["QUX"] = "changed"
+	if v := env.Get("QUX"); v != "qux" {
+		t.Fatalf("Snapshot must copy the map: %q", v)
+	}
+}
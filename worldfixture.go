@@ -0,0 +1,285 @@
+package ose
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"golang.org/x/tools/txtar"
+)
+
+// FakeWorldFixture describes a FakeWorld as a txtar archive like:
+//
+//	clock: 2020-01-01T00:00:00Z
+//	mode: 0755 bin/run.sh
+//	-- [env] --
+//	HOME=/home/user
+//	-- [stdin] --
+//	hello
+//	-- bin/run.sh --
+//	#!/bin/sh
+//
+// The comment may contain "clock: RFC3339", "step: duration" (FakeClock.Duration),
+// "mode: octal path", "dir: path" and "noeol: name" directives.
+// "noeol" strips the newline which txtar adds to the end of the file or the section, as FsSnapshot.Marshal does.
+// The sections [env], [stdin], [stdout] and [stderr] are reserved, and the others are files.
+// Files are created with mode 0644 unless a mode directive is given.
+type FakeWorldFixture struct {
+	Clock   time.Time
+	Step    time.Duration
+	Env     map[string]string
+	Stdin   []byte
+	Outputs map[Stream][]byte
+	Files   *FsSnapshot
+	modes   map[string]bool
+}
+
+func NewFakeWorldFixture() *FakeWorldFixture {
+	return &FakeWorldFixture{
+		Step:    time.Millisecond,
+		Env:     make(map[string]string),
+		Outputs: make(map[Stream][]byte),
+		Files:   &FsSnapshot{Entries: make([]*FsSnapshotEntry, 0)},
+		modes:   make(map[string]bool),
+	}
+}
+
+// ParseFakeWorldFixture parses a fixture formatted as described in FakeWorldFixture.
+func ParseFakeWorldFixture(data []byte) (*FakeWorldFixture, error) {
+	a := txtar.Parse(data)
+	f := NewFakeWorldFixture()
+	modes := make(map[string]os.FileMode)
+	noeol := make(map[string]bool)
+	for _, line := range strings.Split(string(a.Comment), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid directive: %s", line)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		var err error
+		switch key {
+		case "clock":
+			f.Clock, err = time.Parse(time.RFC3339Nano, value)
+		case "step":
+			f.Step, err = time.ParseDuration(value)
+		case "mode":
+			fields := strings.SplitN(value, " ", 2)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("invalid directive: %s", line)
+			}
+			var perm uint64
+			perm, err = strconv.ParseUint(fields[0], 8, 32)
+			modes[fields[1]] = os.FileMode(perm)
+			f.modes[fields[1]] = true
+		case "dir":
+			f.Files.Entries = append(f.Files.Entries, &FsSnapshotEntry{Path: value, Mode: os.ModeDir | 0755})
+		case "noeol":
+			noeol[value] = true
+		default:
+			return nil, fmt.Errorf("unknown directive: %s", line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid directive: %s: %w", line, err)
+		}
+	}
+	for _, file := range a.Files {
+		if noeol[file.Name] {
+			file.Data = bytes.TrimSuffix(file.Data, []byte("\n"))
+		}
+		switch file.Name {
+		case "[env]":
+			for _, line := range strings.Split(string(file.Data), "\n") {
+				if line == "" || strings.HasPrefix(line, "#") {
+					continue
+				}
+				kv := strings.SplitN(line, "=", 2)
+				if len(kv) != 2 {
+					return nil, fmt.Errorf("invalid env: %s", line)
+				}
+				f.Env[kv[0]] = kv[1]
+			}
+		case "[stdin]":
+			f.Stdin = file.Data
+		case "[stdout]":
+			f.Outputs[StreamOut] = append([]byte{}, file.Data...)
+		case "[stderr]":
+			f.Outputs[StreamErr] = append([]byte{}, file.Data...)
+		default:
+			f.Files.Entries = append(f.Files.Entries, &FsSnapshotEntry{Path: file.Name, Mode: 0644, Content: file.Data})
+		}
+	}
+	for _, e := range f.Files.Entries {
+		if mode, ok := modes[e.Path]; ok {
+			e.Mode = e.Mode&os.ModeDir | mode
+		}
+	}
+	f.Files.sort()
+	return f, nil
+}
+
+// LoadFakeWorldFixture reads a fixture from the real filesystem, typically under testdata.
+func LoadFakeWorldFixture(path string) (*FakeWorldFixture, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ParseFakeWorldFixture(bs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// LoadFakeWorld creates a FakeWorld from a fixture file.
+func LoadFakeWorld(path string) (*FakeWorld, error) {
+	f, err := LoadFakeWorldFixture(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.NewFakeWorld()
+}
+
+// NewFakeWorld creates a FakeWorld set up as f describes.
+func (f *FakeWorldFixture) NewFakeWorld() (*FakeWorld, error) {
+	w := NewFakeWorld()
+	if !f.Clock.IsZero() {
+		w.FakeClock.Time = f.Clock
+	}
+	w.FakeClock.Duration = f.Step
+	for k, v := range f.Env {
+		_ = w.FakeEnv.Set(k, v)
+	}
+	w.FakeIO.InBuf.Write(f.Stdin)
+	err := f.Files.Restore(w.FakeFs, "")
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Format formats f as a txtar archive which ParseFakeWorldFixture accepts.
+func (f *FakeWorldFixture) Format() []byte {
+	comment := new(bytes.Buffer)
+	if !f.Clock.IsZero() {
+		fmt.Fprintf(comment, "clock: %s\n", f.Clock.Format(time.RFC3339Nano))
+	}
+	if f.Step != time.Millisecond {
+		fmt.Fprintf(comment, "step: %s\n", f.Step)
+	}
+	// eol adds the newline which txtar requires, and records it by a noeol directive
+	eol := func(name string, data []byte) []byte {
+		if len(data) == 0 || data[len(data)-1] == '\n' {
+			return data
+		}
+		fmt.Fprintf(comment, "noeol: %s\n", name)
+		return append(append([]byte{}, data...), '\n')
+	}
+	a := &txtar.Archive{}
+	for _, e := range f.Files.Entries {
+		if e.IsDir() {
+			fmt.Fprintf(comment, "dir: %s\n", e.Path)
+		} else {
+			a.Files = append(a.Files, txtar.File{Name: e.Path, Data: eol(e.Path, e.Content)})
+		}
+		if f.modes[e.Path] || (e.Mode.Perm() != 0644 && !(e.IsDir() && e.Mode.Perm() == 0755)) {
+			fmt.Fprintf(comment, "mode: %04o %s\n", uint32(e.Mode.Perm()), e.Path)
+		}
+	}
+	reserved := make([]txtar.File, 0)
+	if len(f.Env) != 0 {
+		reserved = append(reserved, txtar.File{Name: "[env]", Data: []byte(strings.Join(environ(f.Env), "\n") + "\n")})
+	}
+	if f.Stdin != nil {
+		reserved = append(reserved, txtar.File{Name: "[stdin]", Data: eol("[stdin]", f.Stdin)})
+	}
+	if out, ok := f.Outputs[StreamOut]; ok {
+		reserved = append(reserved, txtar.File{Name: "[stdout]", Data: eol("[stdout]", out)})
+	}
+	if out, ok := f.Outputs[StreamErr]; ok {
+		reserved = append(reserved, txtar.File{Name: "[stderr]", Data: eol("[stderr]", out)})
+	}
+	a.Comment = comment.Bytes()
+	a.Files = append(reserved, a.Files...)
+	return txtar.Format(a)
+}
+
+// CaptureFakeWorld describes the outputs of w and the files at paths as an expectation for Verify.
+func CaptureFakeWorld(w *FakeWorld, paths ...string) (*FakeWorldFixture, error) {
+	f := NewFakeWorldFixture()
+	f.Outputs[StreamOut] = []byte(w.FakeIO.OutString())
+	f.Outputs[StreamErr] = []byte(w.FakeIO.ErrString())
+	sort.Strings(paths)
+	for _, p := range paths {
+		fi, err := w.FakeFs.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		e := &FsSnapshotEntry{Path: p, Mode: fi.Mode() & (os.ModeDir | os.ModePerm)}
+		if !fi.IsDir() {
+			e.Content, err = afero.ReadFile(w.FakeFs, p)
+			if err != nil {
+				return nil, err
+			}
+		}
+		f.Files.Entries = append(f.Files.Entries, e)
+		f.modes[p] = true
+	}
+	return f, nil
+}
+
+// Verify checks that the outputs and files of w match f as an expectation.
+// Only the outputs and the files described in f are checked, and modes are compared only if given by mode directives.
+func (f *FakeWorldFixture) Verify(w *FakeWorld) error {
+	sb := new(strings.Builder)
+	actuals := map[Stream]string{
+		StreamOut: w.FakeIO.OutString(),
+		StreamErr: w.FakeIO.ErrString(),
+	}
+	for _, s := range []Stream{StreamOut, StreamErr} {
+		expected, ok := f.Outputs[s]
+		if !ok || string(expected) == actuals[s] {
+			continue
+		}
+		fmt.Fprintf(sb, "~ [%s]\n", s)
+		sb.WriteString(DiffLines(string(expected), actuals[s]))
+	}
+	for _, e := range f.Files.Entries {
+		fi, err := w.FakeFs.Stat(e.Path)
+		if err != nil {
+			fmt.Fprintf(sb, "- %s\n", describeEntry(e))
+			continue
+		}
+		if fi.IsDir() != e.IsDir() {
+			fmt.Fprintf(sb, "- %s\n+ %s (type mismatch)\n", describeEntry(e), e.Path)
+			continue
+		}
+		if f.modes[e.Path] && fi.Mode().Perm() != e.Mode.Perm() {
+			fmt.Fprintf(sb, "~ %s: mode %04o -> %04o\n", e.Path, uint32(e.Mode.Perm()), uint32(fi.Mode().Perm()))
+		}
+		if e.IsDir() {
+			continue
+		}
+		bs, err := afero.ReadFile(w.FakeFs, e.Path)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(bs, e.Content) {
+			fmt.Fprintf(sb, "~ %s: content\n", e.Path)
+			sb.WriteString(DiffLines(string(e.Content), string(bs)))
+		}
+	}
+	if sb.Len() != 0 {
+		return fmt.Errorf("fake world mismatch:\n%s", sb.String())
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package ose_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+func runUpper(w ose.World) error {
+	o := ose.NewOpenerInWorld(w)
+	rc, err := o.Open("-")
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	bs, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w.IO().Out(), "%s, %s\n", strings.ToUpper(w.Env().Get("GREETING")), strings.ToUpper(strings.TrimSpace(string(bs))))
+	wc, err := o.Create("out/result.txt")
+	if err != nil {
+		return err
+	}
+	defer wc.Close()
+	_, err = fmt.Fprintln(wc, w.Clock().Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func TestLoadFakeWorld(t *testing.T) {
+	w, err := ose.LoadFakeWorld("testdata/upper.txtar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := w.Fs().Stat("out"); err != nil || !fi.IsDir() {
+		t.Fatalf("out must be a directory: %v", err)
+	}
+	if err := runUpper(w); err != nil {
+		t.Fatal(err)
+	}
+	want, err := ose.LoadFakeWorldFixture("testdata/upper.want.txtar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := want.Verify(w); err != nil {
+		t.Fatal(err)
+	}
+	if now := w.Clock().Now(); !now.Equal(epoch.Add(time.Second)) {
+		t.Fatalf("invalid time: %v", now)
+	}
+}
+
+func TestFakeWorldFixtureMismatch(t *testing.T) {
+	w, err := ose.LoadFakeWorld("testdata/upper.txtar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = w.FakeEnv.Set("GREETING", "bye")
+	if err := runUpper(w); err != nil {
+		t.Fatal(err)
+	}
+	want, err := ose.LoadFakeWorldFixture("testdata/upper.want.txtar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = want.Verify(w)
+	if err == nil || !strings.Contains(err.Error(), "    +BYE, WORLD") {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
+
+func TestFakeWorldFixtureFormat(t *testing.T) {
+	bs, err := ioutil.ReadFile("testdata/upper.txtar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ose.ParseFakeWorldFixture(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2, err := ose.ParseFakeWorldFixture(f.Format())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Format(), f2.Format()) {
+		t.Fatalf("must be stable:\n%s\n%s", f.Format(), f2.Format())
+	}
+	if diff := ose.DiffFsSnapshots(f.Files, f2.Files); diff != "" {
+		t.Fatalf("must be equal:\n%s", diff)
+	}
+
+	w, err := f.NewFakeWorld()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runUpper(w); err != nil {
+		t.Fatal(err)
+	}
+	captured, err := ose.CaptureFakeWorld(w, "out/result.txt", "conf/secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := captured.Verify(w); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFakeWorldFixtureNoEOL(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "foo.txt", []byte("foo"), 0644)
+	afero.WriteFile(w.FakeFs, "bar.txt", []byte("bar\n"), 0644)
+	io.WriteString(w.FakeIO.Out(), "out")
+	captured, err := ose.CaptureFakeWorld(w, "foo.txt", "bar.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ose.ParseFakeWorldFixture(captured.Format())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Verify(w); err != nil {
+		t.Fatal(err)
+	}
+	if s := string(f.Outputs[ose.StreamOut]); s != "out" {
+		t.Fatalf("invalid stdout: %q", s)
+	}
+}
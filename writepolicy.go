@@ -0,0 +1,204 @@
+package ose
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// BackupMode corresponds to --backup=none|simple|numbered|existing of GNU coreutils.
+type BackupMode int
+
+const (
+	BackupNone BackupMode = iota
+	// BackupSimple makes a backup named name+suffix, like foo~.
+	BackupSimple
+	// BackupNumbered makes a backup named name.~N~, like foo.~1~.
+	BackupNumbered
+	// BackupExisting makes a numbered backup if numbered ones exist, or a simple one otherwise.
+	BackupExisting
+)
+
+func ParseBackupMode(s string) (BackupMode, error) {
+	switch s {
+	case "", "none", "off":
+		return BackupNone, nil
+	case "simple", "never":
+		return BackupSimple, nil
+	case "numbered", "t":
+		return BackupNumbered, nil
+	case "existing", "nil":
+		return BackupExisting, nil
+	}
+	return BackupNone, fmt.Errorf("invalid backup mode: %s", s)
+}
+
+func (m BackupMode) String() string {
+	switch m {
+	case BackupSimple:
+		return "simple"
+	case BackupNumbered:
+		return "numbered"
+	case BackupExisting:
+		return "existing"
+	}
+	return "none"
+}
+
+// DefaultBackupSuffix is the suffix of simple backups if Opener.BackupSuffix is empty.
+const DefaultBackupSuffix = "~"
+
+// ClobberError is returned when Opener.NoClobber refuses to overwrite an existing file.
+type ClobberError struct {
+	Name string
+}
+
+func (e *ClobberError) Error() string {
+	return fmt.Sprintf("%s: file exists", e.Name)
+}
+
+// Unwrap returns os.ErrExist, so that errors.Is(err, os.ErrExist) works.
+func (e *ClobberError) Unwrap() error { return os.ErrExist }
+
+// BackupFileName returns the name of a new backup of name in mode.
+func BackupFileName(fs afero.Fs, name string, mode BackupMode, suffix string) (string, error) {
+	if suffix == "" {
+		suffix = DefaultBackupSuffix
+	}
+	if mode == BackupSimple {
+		return name + suffix, nil
+	}
+	fis, err := afero.ReadDir(fs, filepath.Dir(name))
+	if err != nil {
+		return "", err
+	}
+	prefix := filepath.Base(name) + ".~"
+	last := 0
+	for _, fi := range fis {
+		s := fi.Name()
+		if !strings.HasPrefix(s, prefix) || !strings.HasSuffix(s, "~") || len(s) < len(prefix)+2 {
+			continue
+		}
+		n, err := strconv.Atoi(s[len(prefix) : len(s)-1])
+		if err == nil && n > last {
+			last = n
+		}
+	}
+	if mode == BackupExisting && last == 0 {
+		return name + suffix, nil
+	}
+	return fmt.Sprintf("%s.~%d~", name, last+1), nil
+}
+
+func (o *Opener) exists(name string) (bool, error) {
+	_, err := o.fs.Stat(name)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// checkClobber fails with *ClobberError if NoClobber is set and name exists.
+func (o *Opener) checkClobber(name string) error {
+	if !o.NoClobber {
+		return nil
+	}
+	ok, err := o.exists(name)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return &ClobberError{Name: name}
+	}
+	return nil
+}
+
+// backup moves name to its backup, or copies it if keep is true so that name stays in place.
+// It does nothing if Backup is none or name doesn't exist.
+func (o *Opener) backup(name string, keep bool) error {
+	if o.Backup == BackupNone {
+		return nil
+	}
+	ok, err := o.exists(name)
+	if err != nil || !ok {
+		return err
+	}
+	bname, err := BackupFileName(o.fs, name, o.Backup, o.BackupSuffix)
+	if err != nil {
+		return err
+	}
+	if keep {
+		// never hard link: name may be appended to in place
+		return CopyFile(o.fs, name, bname, nil)
+	}
+	return o.fs.Rename(name, bname)
+}
+
+// createPolicyFile opens name for Create according to NoClobber, Backup and Append.
+func (o *Opener) createPolicyFile(name string) (afero.File, error) {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if o.Append {
+		if o.ChecksumSidecar {
+			return nil, fmt.Errorf("%s: checksum sidecar is not supported in append mode", name)
+		}
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	err := o.checkClobber(name)
+	if err != nil {
+		return nil, err
+	}
+	if o.NoClobber {
+		flag |= os.O_EXCL
+	} else {
+		err = o.backup(name, o.Append)
+		if err != nil {
+			return nil, err
+		}
+	}
+	f, err := o.fs.OpenFile(name, flag, 0666)
+	if o.NoClobber && os.IsExist(err) {
+		return nil, &ClobberError{Name: name}
+	}
+	return f, err
+}
+
+// appendExisting writes the content of name to w if Append is set, so that the temporary file f continues it.
+// The mode of name is also copied to f.
+func (o *Opener) appendExisting(f afero.File, w io.Writer, name string) error {
+	if !o.Append {
+		return nil
+	}
+	src, err := o.fs.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	err = o.fs.Chmod(f.Name(), fi.Mode())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+// beforeCommit is called before a temporary file is moved to name.
+// The backup is a copy, so name keeps the original until the move replaces it.
+func (o *Opener) beforeCommit(name string) error {
+	err := o.checkClobber(name)
+	if err != nil {
+		return err
+	}
+	return o.backup(name, true)
+}
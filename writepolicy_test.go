@@ -0,0 +1,306 @@
+package ose_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/taskie/ose"
+)
+
+func writeString(t *testing.T, o *ose.Opener, name, s string) error {
+	t.Helper()
+	wc, err := o.Create(name)
+	if err != nil {
+		return err
+	}
+	io.WriteString(wc, s)
+	return wc.Close()
+}
+
+func createTempString(o *ose.Opener, name, s string) error {
+	_, err := o.CreateTempFile("", "foo", name, func(wc io.WriteCloser) (bool, error) {
+		_, err := io.WriteString(wc, s)
+		return true, err
+	})
+	return err
+}
+
+func assertFile(t *testing.T, fs afero.Fs, name, expected string) {
+	t.Helper()
+	bs, err := afero.ReadFile(fs, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != expected {
+		t.Fatalf("invalid content: %s: %q", name, bs)
+	}
+}
+
+func TestParseBackupMode(t *testing.T) {
+	for s, expected := range map[string]ose.BackupMode{
+		"":         ose.BackupNone,
+		"off":      ose.BackupNone,
+		"simple":   ose.BackupSimple,
+		"never":    ose.BackupSimple,
+		"numbered": ose.BackupNumbered,
+		"t":        ose.BackupNumbered,
+		"existing": ose.BackupExisting,
+		"nil":      ose.BackupExisting,
+	} {
+		m, err := ose.ParseBackupMode(s)
+		if err != nil || m != expected {
+			t.Fatalf("invalid mode: %q: %v", s, m)
+		}
+	}
+	_, err := ose.ParseBackupMode("foo")
+	if err == nil {
+		t.Fatal("must fail")
+	}
+}
+
+func TestOpenerNoClobber(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "foo.txt", []byte("old"), 0644)
+	o := ose.NewOpenerInWorld(w)
+	o.NoClobber = true
+	for _, err := range []error{
+		writeString(t, o, "foo.txt", "new"),
+		createTempString(o, "foo.txt", "new"),
+	} {
+		var cerr *ose.ClobberError
+		if !errors.As(err, &cerr) || cerr.Name != "foo.txt" {
+			t.Fatalf("invalid error: %v", err)
+		}
+		if !errors.Is(err, os.ErrExist) {
+			t.Fatalf("must be os.ErrExist: %v", err)
+		}
+	}
+	assertFile(t, w.FakeFs, "foo.txt", "old")
+	err := writeString(t, o, "bar.txt", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, w.FakeFs, "bar.txt", "new")
+}
+
+func TestOpenerNoClobberCommit(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.NoClobber = true
+	_, err := o.CreateTempFile("", "foo", "foo.txt", func(wc io.WriteCloser) (bool, error) {
+		// another process creates the file meanwhile
+		afero.WriteFile(w.FakeFs, "foo.txt", []byte("other"), 0644)
+		_, err := io.WriteString(wc, "new")
+		return true, err
+	})
+	var cerr *ose.ClobberError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("invalid error: %v", err)
+	}
+	assertFile(t, w.FakeFs, "foo.txt", "other")
+}
+
+func TestOpenerBackup(t *testing.T) {
+	cases := []struct {
+		mode     ose.BackupMode
+		existing []string
+		expected string
+	}{
+		{ose.BackupSimple, nil, "foo.txt~"},
+		{ose.BackupNumbered, nil, "foo.txt.~1~"},
+		{ose.BackupNumbered, []string{"foo.txt.~1~", "foo.txt.~9~"}, "foo.txt.~10~"},
+		{ose.BackupExisting, nil, "foo.txt~"},
+		{ose.BackupExisting, []string{"foo.txt.~2~"}, "foo.txt.~3~"},
+	}
+	for _, c := range cases {
+		for _, atomic := range []bool{false, true} {
+			w := ose.NewFakeWorld()
+			afero.WriteFile(w.FakeFs, "foo.txt", []byte("old"), 0644)
+			for _, name := range c.existing {
+				afero.WriteFile(w.FakeFs, name, []byte("older"), 0644)
+			}
+			o := ose.NewOpenerInWorld(w)
+			o.Backup = c.mode
+			var err error
+			if atomic {
+				err = createTempString(o, "foo.txt", "new")
+			} else {
+				err = writeString(t, o, "foo.txt", "new")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFile(t, w.FakeFs, "foo.txt", "new")
+			assertFile(t, w.FakeFs, c.expected, "old")
+		}
+	}
+}
+
+func TestOpenerBackupSuffix(t *testing.T) {
+	w := ose.NewFakeWorld()
+	o := ose.NewOpenerInWorld(w)
+	o.Backup = ose.BackupSimple
+	o.BackupSuffix = ".bak"
+	err := writeString(t, o, "foo.txt", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := afero.Exists(w.FakeFs, "foo.txt.bak"); ok {
+		t.Fatal("must not back up a new file")
+	}
+	err = writeString(t, o, "foo.txt", "newer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, w.FakeFs, "foo.txt.bak", "new")
+}
+
+func TestOpenerAppend(t *testing.T) {
+	for _, atomic := range []bool{false, true} {
+		w := ose.NewFakeWorld()
+		afero.WriteFile(w.FakeFs, "foo.txt", []byte("old\n"), 0640)
+		o := ose.NewOpenerInWorld(w)
+		o.Append = true
+		o.Backup = ose.BackupSimple
+		var err error
+		if atomic {
+			err = createTempString(o, "foo.txt", "new\n")
+		} else {
+			err = writeString(t, o, "foo.txt", "new\n")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertFile(t, w.FakeFs, "foo.txt", "old\nnew\n")
+		assertFile(t, w.FakeFs, "foo.txt~", "old\n")
+		fi, err := w.FakeFs.Stat("foo.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0640 {
+			t.Fatalf("invalid mode: %v", fi.Mode())
+		}
+	}
+}
+
+func TestOpenerAppendOsFs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fs := afero.NewOsFs()
+	name := filepath.Join(dir, "foo.txt")
+	for _, atomic := range []bool{false, true} {
+		err = afero.WriteFile(fs, name, []byte("old\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(name + "~")
+		o := ose.NewOpener(fs, ose.NewBufIOContainer())
+		o.Append = true
+		o.Backup = ose.BackupSimple
+		if atomic {
+			err = createTempString(o, name, "new\n")
+		} else {
+			err = writeString(t, o, name, "new\n")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertFile(t, fs, name, "old\nnew\n")
+		assertFile(t, fs, name+"~", "old\n")
+	}
+}
+
+func TestOpenerAppendChecksum(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "foo.txt", []byte("foo"), 0644)
+	o := ose.NewOpenerInWorld(w)
+	o.Append = true
+	o.ChecksumSidecar = true
+	err := createTempString(o, "foo.txt", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.ChecksumSidecar = false
+	rc, err := o.OpenVerified("foo.txt", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.Close()
+	if err != nil {
+		t.Fatalf("the sidecar must cover the existing data: %v", err)
+	}
+	assertFile(t, w.FakeFs, "foo.txt", "foobar")
+	o.ChecksumSidecar = true
+	err = writeString(t, o, "foo.txt", "baz")
+	if err == nil {
+		t.Fatal("must fail without the atomic path")
+	}
+}
+
+func TestOpenerCreateAllPolicies(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "foo.txt", []byte("old\n"), 0644)
+	o := ose.NewOpenerInWorld(w)
+	o.Append = true
+	o.Backup = ose.BackupNumbered
+	wc, err := o.CreateAllWithOptions([]string{"foo.txt", "bar.txt"}, &ose.CreateAllOptions{Atomic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(wc, "new\n")
+	err = wc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, w.FakeFs, "foo.txt", "old\nnew\n")
+	assertFile(t, w.FakeFs, "foo.txt.~1~", "old\n")
+	assertFile(t, w.FakeFs, "bar.txt", "new\n")
+
+	o.NoClobber = true
+	_, err = o.CreateAllWithOptions([]string{"foo.txt"}, &ose.CreateAllOptions{Atomic: true})
+	var cerr *ose.ClobberError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
+
+// moveFailingFs fails to rename or write a file to target.
+type moveFailingFs struct {
+	afero.Fs
+	target string
+}
+
+func (fs *moveFailingFs) Rename(oldname, newname string) error {
+	if newname == fs.target {
+		return errors.New("rename failed")
+	}
+	return fs.Fs.Rename(oldname, newname)
+}
+
+func (fs *moveFailingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if name == fs.target && flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		return nil, errors.New("write failed")
+	}
+	return fs.Fs.OpenFile(name, flag, perm)
+}
+
+func TestOpenerBackupCommitFailure(t *testing.T) {
+	w := ose.NewFakeWorld()
+	afero.WriteFile(w.FakeFs, "foo.txt", []byte("old"), 0644)
+	o := ose.NewOpener(&moveFailingFs{Fs: w.FakeFs, target: "foo.txt"}, w.IO())
+	o.Backup = ose.BackupSimple
+	err := createTempString(o, "foo.txt", "new")
+	if err == nil {
+		t.Fatal("must fail")
+	}
+	assertFile(t, w.FakeFs, "foo.txt", "old")
+	assertFile(t, w.FakeFs, "foo.txt~", "old")
+}